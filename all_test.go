@@ -1,76 +1,104 @@
 package main
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	ng "ndgo/ndgo"
+	"ndgo/ndgo/linalg"
+	"ndgo/ndgo/safetensors"
 )
 
 func TestApply(t *testing.T) {
-	a := ng.Random([]int{2, 4, 2})
+	a := ng.Random[float32]([]int{2, 4, 2})
 	_ = ng.Exp(a)
 }
 
 func TestReshape(t *testing.T) {
-	_ = ng.Arange(1, 17, 1).Reshape([]int{2, 2, 4})
+	_ = ng.Arange[float32](1, 17, 1).Reshape([]int{2, 2, 4})
 }
 
-func TestIndices(t *testing.T) {
-	a := ng.Arange(1, 17, 1).Reshape([]int{2, 4, 2})
+func TestIter(t *testing.T) {
+	a := ng.Arange[float32](1, 17, 1).Reshape([]int{2, 4, 2})
 
-	t.Log("Testing n-dimensional indices...")
-	t.Log(a.Idxs.Indices)
+	t.Log("Testing contiguous iteration...")
+	i := 0
+	for it := a.Iter(); it.Next(); i++ {
+		if a.Data[it.Index()] != float32(i+1) {
+			t.Fatalf("bad contiguous iteration at %d", i)
+		}
+	}
 
-	t.Log("Testing 1-dimensional indices...")
-	t.Log(a.Lidxs.Indices)
-	if a.Data[a.Totalsize-1] != a.Data[a.Lidxs.Indices[a.Totalsize-1]] {
-		panic("bad linear indices")
+	t.Log("Testing strided iteration...")
+	b := a.Transpose(nil)
+	i = 0
+	for it := b.Iter(); it.Next(); i++ {
+		if b.Data[it.Index()] != b.At(i) || it.Pos() != i {
+			t.Fatalf("bad strided iteration at %d", i)
+		}
+	}
+	if i != b.Totalsize {
+		t.Fatalf("iterated %d elements, want %d", i, b.Totalsize)
+	}
+
+	it := b.Iter()
+	it.Seek(5)
+	it.Next()
+	t.Log(it.Coords())
+	if b.Data[it.Index()] != b.At(5) {
+		t.Fatal("bad seek")
 	}
 }
 
 func TestTranspose(t *testing.T) {
 	t.Log("Testing 1D transpose...")
-	a := ng.Arange(1, 17, 1)
+	a := ng.Arange[float32](1, 17, 1)
 	b := a.Transpose(nil)
 	t.Log(b.Shape, b.Strides, b.C_ORDER, b.F_ORDER)
-	t.Log(b.Idxs, b.Lidxs)
 
 	t.Log("Testing nD transpose...")
-	a = ng.Arange(1, 17, 1).Reshape([]int{2, 4, 2})
+	a = ng.Arange[float32](1, 17, 1).Reshape([]int{2, 4, 2})
 	b = a.Transpose(nil)
 	t.Log(b.Shape, b.Strides, b.C_ORDER, b.F_ORDER)
-	t.Log(b.Idxs, b.Lidxs)
 	ng.PrettyPrint(b)
 
 	t.Log("Testing nD transpose with axes...")
-	a = ng.Arange(1, 17, 1).Reshape([]int{2, 4, 2})
+	a = ng.Arange[float32](1, 17, 1).Reshape([]int{2, 4, 2})
 	b = a.Transpose([]int{2, 1, 0})
 	t.Log(b.Shape, b.Strides, b.C_ORDER, b.F_ORDER)
-	t.Log(b.Idxs, b.Lidxs)
 	ng.PrettyPrint(b)
 }
 
 func TestNormalTransposeOps(t *testing.T) {
-	a := ng.Arange(1, 17, 1).Reshape([]int{2, 4, 2})
+	a := ng.Arange[float32](1, 17, 1).Reshape([]int{2, 4, 2})
 	b := a.Transpose(nil)
 	c := ng.Add(a, b)
 	t.Log(c.Shape, c.Strides, c.C_ORDER, c.F_ORDER)
-	t.Log(c.Idxs, c.Lidxs)
 	ng.PrettyPrint(c)
 }
 
 func TestMatmul(t *testing.T) {
-	a := ng.Arange(1, 17, 1).Reshape([]int{2, 4, 2})
-	b := ng.Arange(1, 17, 1).Reshape([]int{2, 2, 4})
+	a := ng.Arange[float32](1, 17, 1).Reshape([]int{2, 4, 2})
+	b := ng.Arange[float32](1, 17, 1).Reshape([]int{2, 2, 4})
 	c := ng.Matmul(a, b)
 	t.Log(a.Shape, b.Shape, c.Shape)
 	ng.PrettyPrint(c)
 }
 
 func TestParallelAdd(t *testing.T) {
-	a := ng.Random([]int{100, 100})
-	b := ng.Random([]int{100, 100})
+	a := ng.Random[float32]([]int{100, 100})
+	b := ng.Random[float32]([]int{100, 100})
 
 	start := time.Now()
 	_ = ng.Add(a, b)
@@ -80,11 +108,1595 @@ func TestParallelAdd(t *testing.T) {
 }
 
 func TestSub(t *testing.T) {
-	a := ng.Arange(1, 17, 1).Reshape([]int{2, 4, 2})
-	b := ng.Arange(11, 27, 1).Reshape([]int{2, 4, 2})
+	a := ng.Arange[float32](1, 17, 1).Reshape([]int{2, 4, 2})
+	b := ng.Arange[float32](11, 27, 1).Reshape([]int{2, 4, 2})
 
 	// subtract operation
 	c := ng.Sub(a, b)
 
 	ng.PrettyPrint(c)
 }
+
+func TestDtypes(t *testing.T) {
+	a := ng.Arange[int32](1, 7, 1).Reshape([]int{2, 3})
+	b := ng.Arange[int32](1, 7, 1).Reshape([]int{3, 2})
+	c := ng.Matmul(a, b)
+	if c.At(0) != 22 || c.At(3) != 64 {
+		t.Fatalf("bad int32 matmul: %v", c.Data)
+	}
+
+	d := ng.AsType[float64](c)
+	e := ng.Mul(d, ng.Apply(d, func(x float64) float64 { return 0.5 }))
+	if e.At(0) != 11 || e.Itemsize != 8 {
+		t.Fatalf("bad float64 conversion: %v", e.Data)
+	}
+
+	u := ng.AsType[uint8](ng.Arange[float32](250, 256, 1))
+	v := ng.Add(u, u)
+	if v.At(5) != 254 {
+		t.Fatalf("uint8 add should wrap: %v", v.Data)
+	}
+	ng.PrettyPrint(v)
+}
+
+func TestViews(t *testing.T) {
+	a := ng.Arange[float32](0, 12, 1).Reshape([]int{3, 4})
+
+	// transpose shares data with its parent
+	b := a.Transpose(nil)
+	b.Set(1, 100)
+	if a.At(4) != 100 {
+		t.Fatalf("write through transpose not visible: %v", a.Data)
+	}
+
+	// a[1:3, ::-2]
+	c := a.Slice(ng.Span(1, 3), ng.Range{Start: ng.None, Stop: ng.None, Step: -2})
+	if !ng.CheckShapesEqual(c.Shape, []int{2, 2}) {
+		t.Fatalf("bad slice shape %v", c.Shape)
+	}
+	want := []float32{7, 5, 11, 9}
+	for i, w := range want {
+		if c.At(i) != w {
+			t.Fatalf("bad slice values at %d: got %v want %v", i, c.At(i), w)
+		}
+	}
+
+	c.Set(3, -1)
+	if a.At(9) != -1 {
+		t.Fatalf("write through slice not visible: %v", a.Data)
+	}
+
+	// reshaping a non-contiguous view copies
+	d := c.Reshape([]int{4})
+	d.Set(0, 42)
+	if a.At(7) == 42 || d.C_ORDER != true {
+		t.Fatal("reshape of a non-contiguous view should copy")
+	}
+	ng.PrettyPrint(c)
+}
+
+func TestBroadcastAdd(t *testing.T) {
+	a := ng.Arange[float32](1, 4, 1).Reshape([]int{3, 1})
+	b := ng.Arange[float32](1, 3, 1)
+	c := ng.Add(a, b)
+
+	want := []float32{2, 3, 3, 4, 4, 5}
+	for i, w := range want {
+		if c.At(i) != w {
+			t.Fatalf("bad broadcast add at %d: got %v want %v", i, c.At(i), w)
+		}
+	}
+
+	// a dimension of 1 broadcasts to 0 like to any other size
+	if shape := ng.BroadcastShapes([]int{0}, []int{1}); !ng.CheckShapesEqual(shape, []int{0}) {
+		t.Fatalf("bad broadcast of [0] and [1]: %v", shape)
+	}
+	if shape := ng.BroadcastShapes([]int{2, 1}, []int{0}); !ng.CheckShapesEqual(shape, []int{2, 0}) {
+		t.Fatalf("bad broadcast of [2 1] and [0]: %v", shape)
+	}
+	z := ng.Add(ng.NewArrayFromShape[float32]([]int{3, 1}), ng.NewArrayFromShape[float32]([]int{0}))
+	if !ng.CheckShapesEqual(z.Shape, []int{3, 0}) || z.Totalsize != 0 {
+		t.Fatalf("bad broadcast add of an empty array: %v", z.Shape)
+	}
+	if _, err := ng.TryBroadcastShapes([]int{0}, []int{2}); err == nil {
+		t.Fatal("expected BroadcastError for [0] and [2]")
+	}
+
+	start := time.Now()
+	_ = ng.NewArrayFromShape[float32]([]int{1000, 10000})
+	t.Log("Time taken to create 1e7 elements: ", time.Since(start))
+}
+
+func TestReductions(t *testing.T) {
+	a := ng.Arange[float32](1, 25, 1).Reshape([]int{2, 3, 4})
+
+	s := ng.Sum(a, []int{0, 2}, false)
+	if !ng.CheckShapesEqual(s.Shape, []int{3}) || s.At(0) != 68 || s.At(2) != 132 {
+		t.Fatalf("bad sum over axes: %v %v", s.Shape, s.Data)
+	}
+
+	m := ng.Mean(a, []int{-1}, true)
+	if !ng.CheckShapesEqual(m.Shape, []int{2, 3, 1}) || m.At(5) != 22.5 {
+		t.Fatalf("bad mean with keepdims: %v %v", m.Shape, m.Data)
+	}
+
+	// reductions follow the strides of transposed views
+	b := a.Transpose(nil)
+	mx := ng.Max(b, []int{2}, false)
+	if !ng.CheckShapesEqual(mx.Shape, []int{4, 3}) || mx.At(0) != 13 || mx.At(11) != 24 {
+		t.Fatalf("bad max of transpose: %v %v", mx.Shape, mx.Data)
+	}
+
+	am := ng.ArgMin(ng.Neg(a), nil, false)
+	if am.At(0) != 23 {
+		t.Fatalf("bad argmin: %v", am.Data)
+	}
+
+	p := ng.Prod(ng.Arange[int64](1, 6, 1), nil, false)
+	if p.At(0) != 120 {
+		t.Fatalf("bad prod: %v", p.Data)
+	}
+
+	// integer means truncate, and an empty lane has no integer mean
+	if im := ng.Mean(ng.Arange[int](1, 5, 1), nil, false); im.At(0) != 2 {
+		t.Fatalf("bad integer mean: %v", im.Data)
+	}
+	empty := ng.NewArrayFromShape[int]([]int{0, 2})
+	var verr *ng.ValueError
+	if _, err := ng.TryMean(empty, []int{0}, false); !errors.As(err, &verr) {
+		t.Fatalf("expected ValueError for an empty integer lane, got %v", err)
+	}
+	if fm := ng.Mean(ng.NewArrayFromShape[float64]([]int{0, 2}), []int{0}, false); !math.IsNaN(fm.At(0)) {
+		t.Fatalf("expected NaN for an empty float lane, got %v", fm.Data)
+	}
+
+	// large inputs take the parallel path and stay accurate
+	big := ng.Apply(ng.NewArrayFromShape[float32]([]int{2_000_000}), func(x float32) float32 { return 0.1 })
+	total := ng.Sum(big, nil, false).At(0)
+	if total < 199999.9 || total > 200000.1 {
+		t.Fatalf("inaccurate float32 sum: %v", total)
+	}
+	cols := ng.Min(big.Reshape([]int{1000, 2000}), []int{0}, false)
+	if cols.Totalsize != 2000 || cols.At(1999) != 0.1 {
+		t.Fatalf("bad parallel min: %v", cols.Shape)
+	}
+}
+
+func TestBinaryUfuncs(t *testing.T) {
+	x := ng.Arange[float32](-3, 3, 1).Reshape([]int{2, 3})
+	y := ng.Arange[float32](2, 4, 1).Reshape([]int{2, 1})
+
+	cases := []struct {
+		name string
+		got  *ng.Array[float32]
+		want []float32
+	}{
+		{"Sub", ng.Sub(x, y), []float32{-5, -4, -3, -3, -2, -1}},
+		{"Div", ng.Div(x, y), []float32{-1.5, -1, -0.5, 0, 1.0 / 3, 2.0 / 3}},
+		{"Pow", ng.Pow(x, y), []float32{9, 4, 1, 0, 1, 8}},
+		{"Mod", ng.Mod(x, y), []float32{1, 0, 1, 0, 1, 2}},
+		{"FloorDiv", ng.FloorDiv(x, y), []float32{-2, -1, -1, 0, 0, 0}},
+		{"Maximum", ng.Maximum(x, y), []float32{2, 2, 2, 3, 3, 3}},
+		{"Minimum", ng.Minimum(x, y), []float32{-3, -2, -1, 0, 1, 2}},
+		{"Hypot", ng.Hypot(ng.Arange[float32](3, 4, 1), ng.Arange[float32](4, 5, 1)), []float32{5}},
+		{"CopySign", ng.CopySign(y.Reshape([]int{1, 2}), x.Transpose(nil)), []float32{-2, 3, -2, 3, -2, 3}},
+	}
+	for _, c := range cases {
+		for i, w := range c.want {
+			if d := c.got.At(i) - w; d > 1e-6 || d < -1e-6 {
+				t.Fatalf("%s: got %v at %d, want %v", c.name, c.got.At(i), i, w)
+			}
+		}
+	}
+
+	// integer semantics follow numpy
+	a := ng.Arange[int32](-7, 8, 7)
+	b := ng.Arange[int32](2, 3, 1)
+	if m := ng.Mod(a, b); m.At(0) != 1 || m.At(2) != 1 {
+		t.Fatalf("bad int mod: %v", m.Data)
+	}
+	if f := ng.FloorDiv(a, b); f.At(0) != -4 || f.At(2) != 3 {
+		t.Fatalf("bad int floordiv: %v", f.Data)
+	}
+	if z := ng.Div(a, ng.Sub(b, b)); z.At(0) != 0 {
+		t.Fatalf("int division by zero should give 0: %v", z.Data)
+	}
+	if p := ng.Pow(a, ng.Arange[int32](3, 4, 1)); p.At(0) != -343 {
+		t.Fatalf("bad int pow: %v", p.Data)
+	}
+}
+
+func TestMasks(t *testing.T) {
+	a := ng.Arange[float32](0, 6, 1).Reshape([]int{2, 3})
+	two := ng.Arange[float32](2, 3, 1)
+
+	gt := ng.Greater(a, two)
+	le := ng.LessEqual(a, two)
+	if !ng.All(ng.Xor(gt, le), nil, false).At(0) {
+		t.Fatal("a > 2 and a <= 2 should be complementary")
+	}
+	if !ng.CheckShapesEqual(ng.And(gt, ng.Not(le)).Shape, []int{2, 3}) {
+		t.Fatal("bad mask shape")
+	}
+
+	rows := ng.Any(ng.Equal(a, two), []int{1}, false)
+	if rows.At(0) != true || rows.At(1) != false {
+		t.Fatalf("bad any over rows: %v", rows.Data)
+	}
+
+	nan := ng.Log(ng.Sub(a, ng.Arange[float32](1, 2, 1)))
+	if !ng.IsNaN(nan).At(0) || ng.IsFinite(nan).At(1) || !ng.IsInf(nan).At(1) {
+		t.Fatalf("bad float classification: %v", nan.Data)
+	}
+	ng.PrettyPrint(ng.Or(gt, ng.NotEqual(a, a)))
+}
+
+func TestWhereAndMasks(t *testing.T) {
+	a := ng.Arange[float32](0, 6, 1).Reshape([]int{2, 3})
+	zero := ng.NewArrayFromShape[float32]([]int{1})
+
+	// three-way broadcasting: (2, 3), (2, 3) and (1,)
+	w := ng.Where(ng.Greater(a, ng.Arange[float32](2, 3, 1)), a, zero)
+	want := []float32{0, 0, 0, 3, 4, 5}
+	for i, v := range want {
+		if w.At(i) != v {
+			t.Fatalf("bad where at %d: %v", i, w.Data)
+		}
+	}
+
+	c := ng.Clip(a, 1, 4)
+	if c.At(0) != 1 || c.At(5) != 4 || c.At(2) != 2 {
+		t.Fatalf("bad clip: %v", c.Data)
+	}
+
+	// row mask broadcast along columns
+	rows := ng.Equal(ng.Arange[float32](0, 2, 1).Reshape([]int{2, 1}), zero)
+	sel := ng.MaskedSelect(a, rows)
+	if !ng.CheckShapesEqual(sel.Shape, []int{3}) || sel.At(2) != 2 {
+		t.Fatalf("bad masked select: %v %v", sel.Shape, sel.Data)
+	}
+
+	// assigning through a transposed view changes the parent
+	b := a.Transpose(nil)
+	ng.MaskedAssign(b, ng.Less(b, ng.Arange[float32](2, 3, 1)), -1)
+	want = []float32{-1, -1, 2, 3, 4, 5}
+	for i, v := range want {
+		if a.At(i) != v {
+			t.Fatalf("bad masked assign at %d: %v", i, a.Data)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	a := ng.Arange[float32](0, 6, 1).Reshape([]int{2, 3})
+	b := ng.Arange[float32](0, 4, 1).Reshape([]int{2, 2})
+
+	var berr *ng.BroadcastError
+	if _, err := ng.TryAdd(a, b); !errors.As(err, &berr) {
+		t.Fatalf("expected BroadcastError, got %v", err)
+	}
+	t.Log(berr)
+
+	var serr *ng.ShapeError
+	if _, err := a.TryReshape([]int{4, 2}); !errors.As(err, &serr) {
+		t.Fatalf("expected ShapeError, got %v", err)
+	}
+	if _, err := ng.TryMatmul(a, b); !errors.As(err, &serr) {
+		t.Fatalf("expected ShapeError, got %v", err)
+	}
+	t.Log(serr)
+
+	var aerr *ng.AxisError
+	if _, err := a.TryTranspose([]int{0, 0}); !errors.As(err, &aerr) || aerr.Axis != 0 {
+		t.Fatalf("expected AxisError, got %v", err)
+	}
+	if _, err := ng.TrySum(a, []int{2}, false); !errors.As(err, &aerr) || aerr.Axis != 2 {
+		t.Fatalf("expected AxisError, got %v", err)
+	}
+	t.Log(aerr)
+
+	// the panicking API panics with the same typed errors
+	defer func() {
+		err, ok := recover().(error)
+		if !ok || !errors.As(err, &berr) {
+			t.Fatalf("expected a BroadcastError panic, got %v", err)
+		}
+	}()
+	ng.Mul(a, b)
+}
+
+// naiveMatmul is the straightforward triple loop over strided data,
+// used as a reference for Matmul. Heads must have equal shapes.
+func naiveMatmul[T ng.Numeric](a, b *ng.Array[T]) *ng.Array[T] {
+	head := a.Shape[:a.Ndim-2]
+	m, n, p := a.Shape[a.Ndim-2], a.Shape[a.Ndim-1], b.Shape[b.Ndim-1]
+	res := ng.NewArrayFromShape[T](append(append([]int{}, head...), m, p))
+
+	nd_index := make([]int, len(head))
+	for idx := 0; idx < res.Totalsize/(m*p); idx++ {
+		rem := idx
+		for d := len(head) - 1; d >= 0; d-- {
+			nd_index[d] = rem % head[d]
+			rem /= head[d]
+		}
+		for i := 0; i < m; i++ {
+			for j := 0; j < p; j++ {
+				var sum T
+				for k := 0; k < n; k++ {
+					a_index1d, b_index1d := a.Offset, b.Offset
+					for d := range head {
+						a_index1d += nd_index[d] * a.Strides[d]
+						b_index1d += nd_index[d] * b.Strides[d]
+					}
+					a_index1d += i*a.Strides[a.Ndim-2] + k*a.Strides[a.Ndim-1]
+					b_index1d += k*b.Strides[b.Ndim-2] + j*b.Strides[b.Ndim-1]
+					sum += a.Data[a_index1d/a.Itemsize] * b.Data[b_index1d/b.Itemsize]
+				}
+				res.Set(idx*m*p+i*p+j, sum)
+			}
+		}
+	}
+	return res
+}
+
+func TestMatmulKernel(t *testing.T) {
+	check := func(got, want *ng.Array[float64]) {
+		t.Helper()
+		if !ng.CheckShapesEqual(got.Shape, want.Shape) {
+			t.Fatalf("shape %v, want %v", got.Shape, want.Shape)
+		}
+		for i := 0; i < want.Totalsize; i++ {
+			if d := got.At(i) - want.At(i); d > 1e-9 || d < -1e-9 {
+				t.Fatalf("value %v at %d, want %v", got.At(i), i, want.At(i))
+			}
+		}
+	}
+
+	// larger than one block in every direction, and non-contiguous operands
+	rev := ng.Range{Start: ng.None, Stop: ng.None, Step: -1}
+	a := ng.Random[float64]([]int{2, 150, 300}).Slice(ng.FullRange(), rev)
+	b := ng.Random[float64]([]int{2, 270, 300}).Transpose([]int{0, 2, 1})
+	check(ng.Matmul(a, b), naiveMatmul(a, b))
+
+	// broadcasting heads (3, 1) and (4,)
+	x := ng.Random[float64]([]int{3, 1, 5, 7})
+	y := ng.Random[float64]([]int{4, 7, 6})
+	c := ng.Matmul(x, y)
+	if !ng.CheckShapesEqual(c.Shape, []int{3, 4, 5, 6}) {
+		t.Fatalf("bad broadcast matmul shape %v", c.Shape)
+	}
+	x2 := ng.Add(x, ng.NewArrayFromShape[float64]([]int{3, 4, 5, 7}))
+	y2 := ng.Add(y, ng.NewArrayFromShape[float64]([]int{3, 4, 7, 6}))
+	check(c, naiveMatmul(x2, y2))
+}
+
+func benchmarkMatmul(bench *testing.B, shapeA, shapeB []int, matmul func(a, b *ng.Array[float32]) *ng.Array[float32]) {
+	a := ng.Random[float32](shapeA)
+	b := ng.Random[float32](shapeB)
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		_ = matmul(a, b)
+	}
+}
+
+func BenchmarkMatmul512(b *testing.B) {
+	benchmarkMatmul(b, []int{512, 512}, []int{512, 512}, ng.Matmul[float32])
+}
+
+func BenchmarkMatmulNaive512(b *testing.B) {
+	benchmarkMatmul(b, []int{512, 512}, []int{512, 512}, naiveMatmul[float32])
+}
+
+func BenchmarkMatmulBatched(b *testing.B) {
+	benchmarkMatmul(b, []int{32, 128, 128}, []int{32, 128, 128}, ng.Matmul[float32])
+}
+
+func BenchmarkMatmulNaiveBatched(b *testing.B) {
+	benchmarkMatmul(b, []int{32, 128, 128}, []int{32, 128, 128}, naiveMatmul[float32])
+}
+
+func TestMatmulVectors(t *testing.T) {
+	m := ng.Arange[float64](0, 6, 1).Reshape([]int{2, 3})
+	v := ng.Arange[float64](1, 4, 1)
+	w := ng.Arange[float64](1, 3, 1)
+
+	mv := ng.Matmul(m, v)
+	if !ng.CheckShapesEqual(mv.Shape, []int{2}) || mv.At(0) != 8 || mv.At(1) != 26 {
+		t.Fatalf("bad matrix-vector product: %v %v", mv.Shape, mv.Data)
+	}
+	vm := ng.Matmul(w, m)
+	if !ng.CheckShapesEqual(vm.Shape, []int{3}) || vm.At(2) != 12 {
+		t.Fatalf("bad vector-matrix product: %v %v", vm.Shape, vm.Data)
+	}
+	vv := ng.Matmul(v, v)
+	if !ng.CheckShapesEqual(vv.Shape, []int{1}) || vv.At(0) != 14 {
+		t.Fatalf("bad vector-vector product: %v %v", vv.Shape, vv.Data)
+	}
+
+	// errors report the 1D operands with their own shapes
+	var serr *ng.ShapeError
+	if _, err := ng.TryMatmul(w, v); !errors.As(err, &serr) || !ng.CheckShapesEqual(serr.Shape, []int{3}) {
+		t.Fatalf("expected ShapeError for shape [3], got %v", err)
+	}
+	if _, err := ng.TryMatmul(m, w); !errors.As(err, &serr) || !ng.CheckShapesEqual(serr.Shape, []int{2}) {
+		t.Fatalf("expected ShapeError for shape [2], got %v", err)
+	}
+
+	// batched matrix-vector: (2, 2, 3) @ (3,) -> (2, 2)
+	bm := ng.Matmul(ng.Arange[float64](0, 12, 1).Reshape([]int{2, 2, 3}), v)
+	if !ng.CheckShapesEqual(bm.Shape, []int{2, 2}) || bm.At(3) != 62 {
+		t.Fatalf("bad batched matrix-vector product: %v %v", bm.Shape, bm.Data)
+	}
+
+	// dot contracts the last axis of a with the second-last of b
+	a := ng.Arange[float64](0, 6, 1).Reshape([]int{1, 2, 3})
+	b := ng.Arange[float64](0, 24, 1).Reshape([]int{2, 3, 4})
+	d := ng.Dot(a, b)
+	if !ng.CheckShapesEqual(d.Shape, []int{1, 2, 2, 4}) {
+		t.Fatalf("bad dot shape %v", d.Shape)
+	}
+	// d[0, 1, 1, 2] = sum(a[0, 1, :] * b[1, :, 2])
+	if d.At(14) != 3*14+4*18+5*22 {
+		t.Fatalf("bad dot value %v", d.At(14))
+	}
+
+	in := ng.Inner(m, m)
+	if !ng.CheckShapesEqual(in.Shape, []int{2, 2}) || in.At(1) != 14 {
+		t.Fatalf("bad inner: %v %v", in.Shape, in.Data)
+	}
+	if vd := ng.Vdot(m, m.Transpose(nil).Copy().Transpose(nil)); vd.At(0) != 55 {
+		t.Fatalf("bad vdot: %v", vd.Data)
+	}
+	out := ng.Outer(v, w)
+	if !ng.CheckShapesEqual(out.Shape, []int{3, 2}) || out.At(5) != 6 {
+		t.Fatalf("bad outer: %v %v", out.Shape, out.Data)
+	}
+}
+
+func TestEinsum(t *testing.T) {
+	check := func(name string, got, want *ng.Array[float64]) {
+		t.Helper()
+		if !ng.CheckShapesEqual(got.Shape, want.Shape) {
+			t.Fatalf("%s: shape %v, want %v", name, got.Shape, want.Shape)
+		}
+		for i := 0; i < want.Totalsize; i++ {
+			if d := got.At(i) - want.At(i); d > 1e-9 || d < -1e-9 {
+				t.Fatalf("%s: value %v at %d, want %v", name, got.At(i), i, want.At(i))
+			}
+		}
+	}
+
+	a := ng.Random[float64]([]int{3, 4})
+	b := ng.Random[float64]([]int{4, 5})
+	c := ng.Random[float64]([]int{5, 2})
+	check("matmul", ng.Einsum("ij,jk->ik", a, b), ng.Matmul(a, b))
+	check("implicit", ng.Einsum("ij,jk", a, b), ng.Matmul(a, b))
+	check("transpose", ng.Einsum("ij->ji", a), a.Transpose(nil))
+	check("implicit transpose", ng.Einsum("ba", a), a.Transpose(nil))
+	check("sum", ng.Einsum("ij->", a), ng.Sum(a, nil, false))
+	check("column sums", ng.Einsum("ij->j", a), ng.Sum(a, []int{0}, false))
+	check("chain", ng.Einsum("ij,jk,kl->il", a, b, c), ng.Matmul(ng.Matmul(a, b), c))
+
+	// repeated labels take the diagonal
+	sq := ng.Arange[float64](0, 9, 1).Reshape([]int{3, 3})
+	if tr := ng.Einsum("ii", sq); !ng.CheckShapesEqual(tr.Shape, []int{1}) || tr.At(0) != 12 {
+		t.Fatalf("bad trace: %v %v", tr.Shape, tr.Data)
+	}
+	diag := ng.Einsum("ii->i", sq)
+	if diag.At(0) != 0 || diag.At(1) != 4 || diag.At(2) != 8 {
+		t.Fatalf("bad diagonal: %v", diag.Data)
+	}
+	// the result is a copy
+	diag.Set(0, 100)
+	if sq.At(0) != 0 {
+		t.Fatal("einsum result shares data with its operand")
+	}
+
+	// outer product, and a contraction against a naive loop
+	v := ng.Arange[float64](1, 4, 1)
+	w := ng.Arange[float64](1, 3, 1)
+	check("outer", ng.Einsum("i,j", v, w), ng.Outer(v, w))
+
+	x := ng.Random[float64]([]int{2, 3, 4})
+	y := ng.Random[float64]([]int{4, 3, 5})
+	got := ng.Einsum("abc,cbd->ad", x, y)
+	want := ng.NewArrayFromShape[float64]([]int{2, 5})
+	for i := 0; i < 2; i++ {
+		for l := 0; l < 5; l++ {
+			var sum float64
+			for j := 0; j < 3; j++ {
+				for k := 0; k < 4; k++ {
+					sum += x.At(i*12+j*4+k) * y.At(k*15+j*5+l)
+				}
+			}
+			want.Set(i*5+l, sum)
+		}
+	}
+	check("naive", got, want)
+
+	// ellipsis broadcasts the batch dimensions, like Matmul
+	p := ng.Random[float64]([]int{3, 1, 2, 4})
+	q := ng.Random[float64]([]int{2, 4, 5})
+	check("ellipsis", ng.Einsum("...ij,...jk->...ik", p, q), ng.Matmul(p, q))
+	check("implicit ellipsis", ng.Einsum("...ij,...jk", p, q), ng.Matmul(p, q))
+
+	// batched chain of several operands
+	r := ng.Random[float64]([]int{2, 3, 4})
+	s := ng.Random[float64]([]int{2, 4, 6})
+	u := ng.Random[float64]([]int{2, 6, 2})
+	check("batched chain", ng.Einsum("bij,bjk,bkl->bil", r, s, u), ng.Matmul(ng.Matmul(r, s), u))
+
+	var valueErr *ng.ValueError
+	if _, err := ng.TryEinsum("ij,jk->ix", a, b); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for unknown output label, got %v", err)
+	}
+	if _, err := ng.TryEinsum("ijk", a); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for wrong number of labels, got %v", err)
+	}
+	var shapeErr *ng.ShapeError
+	if _, err := ng.TryEinsum("ij,ij->i", a, b); !errors.As(err, &shapeErr) {
+		t.Fatalf("expected ShapeError for mismatched sizes, got %v", err)
+	}
+}
+
+func TestTensordotKron(t *testing.T) {
+	a := ng.Random[float64]([]int{3, 4, 5})
+	b := ng.Random[float64]([]int{4, 3, 2})
+
+	// contract axes (0, 1) of a with (1, 0) of b, compared with einsum
+	got := ng.Tensordot(a, b, []int{0, 1}, []int{1, 0})
+	want := ng.Einsum("ijk,jil->kl", a, b)
+	if !ng.CheckShapesEqual(got.Shape, []int{5, 2}) {
+		t.Fatalf("bad tensordot shape %v", got.Shape)
+	}
+	for i := 0; i < want.Totalsize; i++ {
+		if d := got.At(i) - want.At(i); d > 1e-9 || d < -1e-9 {
+			t.Fatalf("tensordot value %v at %d, want %v", got.At(i), i, want.At(i))
+		}
+	}
+
+	// transposed operand and negative axes
+	at := a.Transpose([]int{2, 0, 1})
+	got = ng.Tensordot(at, b, []int{-1}, []int{0})
+	want = ng.Einsum("kij,jml->kiml", at, b)
+	for i := 0; i < want.Totalsize; i++ {
+		if d := got.At(i) - want.At(i); d > 1e-9 || d < -1e-9 {
+			t.Fatalf("transposed tensordot value %v at %d, want %v", got.At(i), i, want.At(i))
+		}
+	}
+	if full := ng.Tensordot(a, a, []int{0, 1, 2}, []int{0, 1, 2}); full.Shape[0] != 1 || full.Ndim != 1 {
+		t.Fatalf("full contraction should have shape [1], got %v", full.Shape)
+	}
+
+	var shapeErr *ng.ShapeError
+	if _, err := ng.TryTensordot(a, b, []int{0}, []int{0}); !errors.As(err, &shapeErr) {
+		t.Fatalf("expected ShapeError, got %v", err)
+	}
+	var axisErr *ng.AxisError
+	if _, err := ng.TryTensordot(a, b, []int{3}, []int{0}); !errors.As(err, &axisErr) {
+		t.Fatalf("expected AxisError, got %v", err)
+	}
+
+	x := ng.Arange[int](1, 5, 1).Reshape([]int{2, 2})
+	y := ng.Arange[int](0, 6, 1).Reshape([]int{1, 2, 3})
+	k := ng.Kron(x, y)
+	if !ng.CheckShapesEqual(k.Shape, []int{1, 4, 6}) {
+		t.Fatalf("bad kron shape %v", k.Shape)
+	}
+	// row 1 is x[0, :] (x) y[0, 1, :] = [3 4 5 6 8 10]
+	for j, v := range []int{3, 4, 5, 6, 8, 10} {
+		if k.At(6+j) != v {
+			t.Fatalf("bad kron row: %v", k.Data[6:12])
+		}
+	}
+}
+
+// fails unless got and want have the same shape and values within tol
+func assertClose[T ng.Float](t *testing.T, name string, got, want *ng.Array[T], tol float64) {
+	t.Helper()
+	if !ng.CheckShapesEqual(got.Shape, want.Shape) {
+		t.Fatalf("%s: shape %v, want %v", name, got.Shape, want.Shape)
+	}
+	for i := 0; i < want.Totalsize; i++ {
+		if d := float64(got.At(i) - want.At(i)); d > tol || d < -tol {
+			t.Fatalf("%s: value %v at %d, want %v", name, got.At(i), i, want.At(i))
+		}
+	}
+}
+
+func TestLinalgLU(t *testing.T) {
+	// batch of 2 diagonally dominant, so well conditioned, matrices
+	a := ng.Random[float64]([]int{2, 4, 4})
+	for b := 0; b < 2; b++ {
+		for i := 0; i < 4; i++ {
+			a.Set(b*16+i*5, a.At(b*16+i*5)+4)
+		}
+	}
+
+	p, l, u := linalg.LU(a)
+	assertClose(t, "lu", ng.Matmul(p, ng.Matmul(l, u)), a, 1e-12)
+	if l.At(1) != 0 || l.At(0) != 1 || u.At(4) != 0 {
+		t.Fatalf("bad triangles l=%v u=%v", l.Data[:16], u.Data[:16])
+	}
+
+	// b of shape (4, 3) is broadcast against the batch of a
+	b := ng.Random[float64]([]int{4, 3})
+	x := linalg.Solve(a, b)
+	if !ng.CheckShapesEqual(x.Shape, []int{2, 4, 3}) {
+		t.Fatalf("bad solve shape %v", x.Shape)
+	}
+	assertClose(t, "solve", ng.Matmul(a, x), ng.BroadcastTo(b, []int{2, 4, 3}), 1e-12)
+	v := ng.Arange[float64](1, 5, 1)
+	xv := linalg.Solve(a, v)
+	assertClose(t, "solve vector", ng.Einsum("bij,bj->bi", a, xv), ng.BroadcastTo(v, []int{2, 4}), 1e-12)
+
+	inv := linalg.Inv(a)
+	eye := ng.NewArrayFromShape[float64]([]int{4, 4})
+	for i := 0; i < 4; i++ {
+		eye.Set(i*5, 1)
+	}
+	assertClose(t, "inv", ng.Matmul(a, inv), ng.BroadcastTo(eye, []int{2, 4, 4}), 1e-12)
+
+	m := ng.NewArrayFromShape[float32]([]int{3, 3})
+	m.FromValues([]float32{2, -1, 0, 1, 3, 2, 0, 1, 4})
+	if d := linalg.Det(m); !ng.CheckShapesEqual(d.Shape, []int{1}) || d.At(0) < 23.999 || d.At(0) > 24.001 {
+		t.Fatalf("bad det %v", d.Data)
+	}
+	sign, logdet := linalg.SlogDet(ng.Neg(m))
+	if sign.At(0) != -1 || logdet.At(0) < 3.1780 || logdet.At(0) > 3.1781 {
+		t.Fatalf("bad slogdet %v %v", sign.Data, logdet.Data)
+	}
+	dets := linalg.Det(a)
+	s, l := linalg.SlogDet(a)
+	for i := 0; i < 2; i++ {
+		if d := dets.At(i) - s.At(i)*math.Exp(l.At(i)); d > 1e-9 || d < -1e-9 {
+			t.Fatalf("det %v does not match slogdet", dets.At(i))
+		}
+	}
+
+	singular := ng.NewArrayFromShape[float64]([]int{2, 2})
+	singular.FromValues([]float64{1, 2, 2, 4})
+	var singularErr *linalg.SingularError
+	if _, err := linalg.TryInv(singular); !errors.As(err, &singularErr) {
+		t.Fatalf("expected SingularError, got %v", err)
+	}
+	if _, err := linalg.TrySolve(singular, v.Slice(ng.Span(0, 2))); !errors.As(err, &singularErr) {
+		t.Fatalf("expected SingularError, got %v", err)
+	}
+	if d := linalg.Det(singular); d.At(0) != 0 {
+		t.Fatalf("singular det %v", d.At(0))
+	}
+
+	// the determinant of a 0x0 matrix is 1, the empty product
+	empty := ng.Zeros[float64]([]int{2, 0, 0})
+	if d := linalg.Det(empty); !ng.CheckShapesEqual(d.Shape, []int{2}) || d.At(0) != 1 || d.At(1) != 1 {
+		t.Fatalf("bad det of 0x0 matrices %v %v", d.Shape, d.Data)
+	}
+	if sign, logdet := linalg.SlogDet(empty); !ng.CheckShapesEqual(sign.Shape, []int{2}) || sign.At(1) != 1 || logdet.At(1) != 0 {
+		t.Fatalf("bad slogdet of 0x0 matrices %v %v", sign, logdet)
+	}
+	var shapeErr *ng.ShapeError
+	if _, err := linalg.TryInv(b); !errors.As(err, &shapeErr) {
+		t.Fatalf("expected ShapeError, got %v", err)
+	}
+}
+
+func TestLinalgQRCholeskyLstsq(t *testing.T) {
+	a := ng.Random[float64]([]int{3, 5, 3})
+	for _, mode := range []linalg.QRMode{linalg.Reduced, linalg.Complete} {
+		q, r := linalg.QR(a, mode)
+		assertClose(t, "qr", ng.Matmul(q, r), a, 1e-12)
+		qtq := ng.Matmul(q.Transpose([]int{0, 2, 1}), q)
+		k := q.Shape[2]
+		for i := 0; i < k; i++ {
+			for j := 0; j < k; j++ {
+				want := 0.0
+				if i == j {
+					want = 1
+				}
+				if d := qtq.At(i*k+j) - want; d > 1e-12 || d < -1e-12 {
+					t.Fatalf("q of mode %d is not orthonormal: %v", mode, qtq.Data[:k*k])
+				}
+			}
+		}
+		if r.At(r.Shape[2]*1+0) != 0 {
+			t.Fatalf("r is not upper triangular: %v", r.Data)
+		}
+	}
+	q, r := linalg.QR(a, linalg.Reduced)
+	if !ng.CheckShapesEqual(q.Shape, []int{3, 5, 3}) || !ng.CheckShapesEqual(r.Shape, []int{3, 3, 3}) {
+		t.Fatalf("bad reduced shapes %v %v", q.Shape, r.Shape)
+	}
+	q, r = linalg.QR(a, linalg.Complete)
+	if !ng.CheckShapesEqual(q.Shape, []int{3, 5, 5}) || !ng.CheckShapesEqual(r.Shape, []int{3, 5, 3}) {
+		t.Fatalf("bad complete shapes %v %v", q.Shape, r.Shape)
+	}
+
+	// a^T a is positive definite, float32 storage
+	a32 := ng.AsType[float32](a)
+	spd := ng.Matmul(a32.Transpose([]int{0, 2, 1}), a32)
+	l := linalg.Cholesky(spd)
+	assertClose(t, "cholesky", ng.Matmul(l, l.Transpose([]int{0, 2, 1})), spd, 1e-5)
+	if l.At(1) != 0 {
+		t.Fatalf("l is not lower triangular: %v", l.Data[:9])
+	}
+	var pdErr *linalg.NotPositiveDefiniteError
+	if _, err := linalg.TryCholesky(ng.Neg(spd)); !errors.As(err, &pdErr) {
+		t.Fatalf("expected NotPositiveDefiniteError, got %v", err)
+	}
+
+	// exact fit: y = 2 + 3x
+	x := ng.Arange[float64](0, 5, 1)
+	design := ng.NewArrayFromShape[float64]([]int{5, 2})
+	for i := 0; i < 5; i++ {
+		design.Set(2*i, 1)
+		design.Set(2*i+1, x.At(i))
+	}
+	y := ng.Add(ng.Mul(x, ng.Arange[float64](3, 4, 1)), ng.Arange[float64](2, 3, 1))
+	coef, res, rank := linalg.Lstsq(design, y)
+	if rank.At(0) != 2 || res.At(0) > 1e-20 {
+		t.Fatalf("bad lstsq rank %v residuals %v", rank.Data, res.Data)
+	}
+	if d := coef.At(0) - 2; d > 1e-12 || d < -1e-12 {
+		t.Fatalf("bad lstsq coefficients %v", coef.Data)
+	}
+
+	// noisy overdetermined batch: the residual is orthogonal to the columns of a
+	b := ng.Random[float64]([]int{5, 2})
+	sol, res, rank := linalg.Lstsq(a, b)
+	if !ng.CheckShapesEqual(sol.Shape, []int{3, 3, 2}) || !ng.CheckShapesEqual(res.Shape, []int{3, 2}) || rank.At(2) != 3 {
+		t.Fatalf("bad lstsq shapes %v %v %v", sol.Shape, res.Shape, rank.Data)
+	}
+	resid := ng.Sub(ng.BroadcastTo(b, []int{3, 5, 2}), ng.Matmul(a, sol))
+	normal := ng.Matmul(a.Transpose([]int{0, 2, 1}), resid)
+	assertClose(t, "normal equations", normal, ng.NewArrayFromShape[float64]([]int{3, 3, 2}), 1e-12)
+	assertClose(t, "residuals", ng.Sum(ng.Mul(resid, resid), []int{1}, false), res, 1e-12)
+
+	// rank deficient: repeated column
+	dup := ng.NewArrayFromShape[float64]([]int{3, 2})
+	dup.FromValues([]float64{1, 1, 2, 2, 3, 3})
+	if _, _, rank := linalg.Lstsq(dup, ng.Arange[float64](0, 3, 1)); rank.At(0) != 1 {
+		t.Fatalf("rank of duplicated columns %v", rank.Data)
+	}
+}
+
+func TestLinalgSVDEigh(t *testing.T) {
+	for _, shape := range [][]int{{2, 5, 3}, {2, 3, 5}} {
+		a := ng.Random[float64](shape)
+		m, n := shape[1], shape[2]
+		k := min(m, n)
+
+		u, s, vt := linalg.SVD(a, false)
+		if !ng.CheckShapesEqual(u.Shape, []int{2, m, k}) || !ng.CheckShapesEqual(vt.Shape, []int{2, k, n}) {
+			t.Fatalf("bad thin svd shapes %v %v", u.Shape, vt.Shape)
+		}
+		us := ng.Mul(u, s.Reshape([]int{2, 1, k}))
+		assertClose(t, "thin svd", ng.Matmul(us, vt), a, 1e-12)
+		for i := 1; i < k; i++ {
+			if s.At(i) > s.At(i-1) {
+				t.Fatalf("singular values are not decreasing: %v", s.Data)
+			}
+		}
+
+		u, s, vt = linalg.SVD(a, true)
+		if !ng.CheckShapesEqual(u.Shape, []int{2, m, m}) || !ng.CheckShapesEqual(vt.Shape, []int{2, n, n}) {
+			t.Fatalf("bad full svd shapes %v %v", u.Shape, vt.Shape)
+		}
+		eye := ng.Einsum("bij,bkj->bik", u, u)
+		for b := 0; b < 2; b++ {
+			for i := 0; i < m; i++ {
+				if d := eye.At(b*m*m+i*m+i) - 1; d > 1e-12 || d < -1e-12 {
+					t.Fatalf("full u is not orthogonal")
+				}
+			}
+		}
+		ut := u.Slice(ng.FullRange(), ng.FullRange(), ng.Span(0, k))
+		vtt := vt.Slice(ng.FullRange(), ng.Span(0, k))
+		assertClose(t, "full svd", ng.Matmul(ng.Mul(ut, s.Reshape([]int{2, 1, k})), vtt), a, 1e-12)
+
+		// a @ pinv(a) @ a = a
+		p := linalg.Pinv(a)
+		if !ng.CheckShapesEqual(p.Shape, []int{2, n, m}) {
+			t.Fatalf("bad pinv shape %v", p.Shape)
+		}
+		assertClose(t, "pinv", ng.Matmul(a, ng.Matmul(p, a)), a, 1e-12)
+		if r := linalg.MatrixRank(a); r.At(0) != k || r.At(1) != k {
+			t.Fatalf("bad rank %v", r.Data)
+		}
+	}
+
+	// rank 1 outer product
+	v := ng.Arange[float64](1, 5, 1)
+	low := ng.Outer(v, v.Slice(ng.Span(0, 3)))
+	if r := linalg.MatrixRank(low); r.At(0) != 1 {
+		t.Fatalf("rank of outer product %v", r.Data)
+	}
+	u, s, vt := linalg.SVD(low, true)
+	assertClose(t, "rank deficient svd", ng.Matmul(ng.Mul(u.Slice(ng.FullRange(), ng.Span(0, 3)), s), vt), low, 1e-12)
+	assertClose(t, "rank deficient pinv", ng.Matmul(low, ng.Matmul(linalg.Pinv(low), low)), low, 1e-12)
+
+	// empty matrices, whose singular values broadcast to shape [0]
+	if p, r := linalg.Pinv(ng.Zeros[float64]([]int{0, 3})), linalg.MatrixRank(ng.Zeros[float64]([]int{0, 3})); !ng.CheckShapesEqual(p.Shape, []int{3, 0}) || r.At(0) != 0 {
+		t.Fatalf("bad pinv or rank of an empty matrix %v %v", p.Shape, r)
+	}
+
+	// a rank 2 float32 matrix, whose rounding noise is not rank
+	sevenths := ng.Div(ng.Arange[float32](1, 13, 1), ng.Full([]int{1}, float32(7)))
+	lowf := ng.Matmul(sevenths.Reshape([]int{6, 2}), ng.Sub(sevenths, ng.Full([]int{1}, float32(1))).Reshape([]int{2, 6}))
+	if r := linalg.MatrixRank(lowf); r.At(0) != 2 {
+		t.Fatalf("rank of float32 rank 2 matrix %v", r.Data)
+	}
+	assertClose(t, "float32 pinv", ng.Matmul(lowf, ng.Matmul(linalg.Pinv(lowf), lowf)), lowf, 1e-4)
+
+	// symmetric batch, float32 storage
+	x := ng.Random[float32]([]int{3, 4, 4})
+	sym := ng.Add(x, x.Transpose([]int{0, 2, 1}))
+	w, vecs := linalg.EighSymmetric(sym)
+	if !ng.CheckShapesEqual(w.Shape, []int{3, 4}) || !ng.CheckShapesEqual(vecs.Shape, []int{3, 4, 4}) {
+		t.Fatalf("bad eigh shapes %v %v", w.Shape, vecs.Shape)
+	}
+	recon := ng.Matmul(ng.Mul(vecs, w.Reshape([]int{3, 1, 4})), vecs.Transpose([]int{0, 2, 1}))
+	assertClose(t, "eigh", recon, sym, 1e-5)
+	for i := 1; i < 4; i++ {
+		if w.At(i) < w.At(i-1) {
+			t.Fatalf("eigenvalues are not increasing: %v", w.Data[:4])
+		}
+	}
+}
+
+func TestDiagonalTrace(t *testing.T) {
+	a := ng.Arange[int](0, 24, 1).Reshape([]int{2, 3, 4})
+
+	// diagonals of the last two axes, as a view
+	d := a.Diagonal(0, 1, 2)
+	if !ng.CheckShapesEqual(d.Shape, []int{2, 3}) || d.At(1) != 5 || d.At(5) != 22 {
+		t.Fatalf("bad diagonal: %v", d.Shape)
+	}
+	d.Set(0, 100)
+	if a.At(0) != 100 {
+		t.Fatal("diagonal is not a view")
+	}
+	a.Set(0, 0)
+
+	if up := a.Diagonal(1, -2, -1); !ng.CheckShapesEqual(up.Shape, []int{2, 3}) || up.At(2) != 11 {
+		t.Fatalf("bad offset diagonal: %v", up.Shape)
+	}
+	if low := a.Diagonal(-1, 1, 2); !ng.CheckShapesEqual(low.Shape, []int{2, 2}) || low.At(1) != 9 {
+		t.Fatalf("bad negative offset diagonal: %v", low.Shape)
+	}
+	// axes 0 and 2, the remaining axis comes first
+	if d02 := a.Diagonal(0, 0, 2); !ng.CheckShapesEqual(d02.Shape, []int{3, 2}) || d02.At(1) != 13 {
+		t.Fatalf("bad diagonal over axes 0 and 2: %v", d02.Shape)
+	}
+
+	tr := ng.Trace(a, 0, 1, 2)
+	if !ng.CheckShapesEqual(tr.Shape, []int{2}) || tr.At(0) != 15 || tr.At(1) != 51 {
+		t.Fatalf("bad trace: %v %v", tr.Shape, tr.Data)
+	}
+	if tr := ng.Trace(a.Slice(ng.Span(0, 1)).Reshape([]int{3, 4}), 1, 0, 1); tr.At(0) != 18 {
+		t.Fatalf("bad offset trace: %v", tr.Data)
+	}
+
+	m := ng.Diag(ng.Arange[int](1, 4, 1), -1)
+	if !ng.CheckShapesEqual(m.Shape, []int{4, 4}) || m.At(4) != 1 || m.At(14) != 3 || ng.Sum(m, nil, false).At(0) != 6 {
+		t.Fatalf("bad diag matrix: %v", m.Data)
+	}
+	if v := ng.Diag(m, -1); !ng.CheckShapesEqual(v.Shape, []int{3}) || v.At(2) != 3 {
+		t.Fatalf("bad extracted diag: %v", v.Data)
+	}
+	var axisErr *ng.AxisError
+	if _, err := a.TryDiagonal(0, 1, 1); !errors.As(err, &axisErr) {
+		t.Fatalf("expected AxisError, got %v", err)
+	}
+}
+
+func TestLinalgNormPower(t *testing.T) {
+	m := ng.NewArrayFromShape[float64]([]int{2, 2})
+	m.FromValues([]float64{1, -2, 3, 4})
+
+	cases := []struct {
+		ord  linalg.Ord
+		want float64
+	}{
+		{linalg.Ord{}, math.Sqrt(30)},
+		{linalg.Fro, math.Sqrt(30)},
+		{linalg.P(1), 6},
+		{linalg.P(-1), 4},
+		{linalg.P(math.Inf(1)), 7},
+		{linalg.P(math.Inf(-1)), 3},
+	}
+	for _, c := range cases {
+		if got := linalg.Norm(m, c.ord, nil, false).At(0); math.Abs(got-c.want) > 1e-12 {
+			t.Fatalf("norm %v: %v, want %v", c.ord, got, c.want)
+		}
+	}
+	// the 2-norm and nuclear norm from the singular values
+	_, s, _ := linalg.SVD(m, false)
+	if got := linalg.Norm(m, linalg.P(2), nil, false).At(0); math.Abs(got-s.At(0)) > 1e-12 {
+		t.Fatalf("2-norm %v, want %v", got, s.At(0))
+	}
+	if got := linalg.Norm(m, linalg.Nuclear, nil, false).At(0); math.Abs(got-s.At(0)-s.At(1)) > 1e-12 {
+		t.Fatalf("nuclear norm %v", got)
+	}
+
+	// vector norms along an axis
+	rows := linalg.Norm(m, linalg.P(1), []int{1}, true)
+	if !ng.CheckShapesEqual(rows.Shape, []int{2, 1}) || rows.At(0) != 3 || rows.At(1) != 7 {
+		t.Fatalf("bad row norms %v %v", rows.Shape, rows.Data)
+	}
+	if cols := linalg.Norm(m, linalg.P(3), []int{0}, false); math.Abs(cols.At(0)-math.Cbrt(28)) > 1e-12 {
+		t.Fatalf("bad 3-norm %v", cols.Data)
+	}
+	batch := ng.Random[float64]([]int{3, 2, 2})
+	if n := linalg.Norm(batch, linalg.Fro, []int{-2, -1}, false); !ng.CheckShapesEqual(n.Shape, []int{3}) {
+		t.Fatalf("bad batched norm shape %v", n.Shape)
+	}
+	var valueErr *ng.ValueError
+	if _, err := linalg.TryNorm(m, linalg.Nuclear, []int{0}, false); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError, got %v", err)
+	}
+
+	p5 := linalg.MatrixPower(m, 5)
+	naive := m
+	for i := 1; i < 5; i++ {
+		naive = ng.Matmul(naive, m)
+	}
+	assertClose(t, "matrix power", p5, naive, 1e-9)
+	assertClose(t, "negative power", linalg.MatrixPower(m, -2), linalg.Inv(ng.Matmul(m, m)), 1e-12)
+	if id := linalg.MatrixPower(batch, 0); id.At(0) != 1 || id.At(1) != 0 || id.At(7) != 1 {
+		t.Fatalf("bad zeroth power %v", id.Data)
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	shape := []int{200, 50}
+	a := ng.NewGenerator(42).Normal(1, 2, shape)
+	b := ng.NewGenerator(42).Normal(1, 2, shape)
+	if !ng.All(ng.Equal(a, b), nil, false).At(0) {
+		t.Fatal("generators with the same seed differ")
+	}
+	if c := ng.NewGenerator(43).Normal(1, 2, shape); ng.All(ng.Equal(a, c), nil, false).At(0) {
+		t.Fatal("generators with different seeds agree")
+	}
+
+	// sample moments of 10000 draws
+	moments := func(name string, arr *ng.Array[float64], mean, variance float64) {
+		t.Helper()
+		m := ng.Mean(arr, nil, false).At(0)
+		d := ng.Sub(arr, ng.Mean(arr, nil, false))
+		v := ng.Mean(ng.Mul(d, d), nil, false).At(0)
+		if math.Abs(m-mean) > 0.05*math.Max(1, math.Abs(mean)) || math.Abs(v-variance) > 0.1*math.Max(1, variance) {
+			t.Fatalf("%s: mean %v variance %v, want %v %v", name, m, v, mean, variance)
+		}
+	}
+	g := ng.NewGenerator(7)
+	moments("normal", g.Normal(1, 2, shape), 1, 4)
+	moments("uniform", g.Uniform(-1, 3, shape), 1, 16.0/12)
+	moments("exponential", g.Exponential(2, shape), 2, 4)
+	moments("gamma", g.Gamma(3, 2, shape), 6, 12)
+	moments("small gamma", g.Gamma(0.5, 1, shape), 0.5, 0.5)
+	moments("beta", g.Beta(2, 5, shape), 2.0/7, 10.0/(49*8))
+	moments("poisson", ng.AsType[float64](g.Poisson(3, shape)), 3, 3)
+	moments("large poisson", ng.AsType[float64](g.Poisson(50, shape)), 50, 50)
+
+	ints := g.Integers(-3, 3, shape)
+	if ng.Min(ints, nil, false).At(0) != -3 || ng.Max(ints, nil, false).At(0) != 2 {
+		t.Fatal("integers are not drawn from [low, high)")
+	}
+	coins := g.Bernoulli(0.25, shape)
+	if f := float64(ng.MaskedSelect(coins, coins).Totalsize) / 10000; math.Abs(f-0.25) > 0.02 {
+		t.Fatalf("bernoulli frequency %v", f)
+	}
+
+	// without replacement every index appears at most once
+	picks := g.Choice(10, []int{10}, false, nil)
+	seen := map[int]bool{}
+	for _, v := range picks.Data {
+		seen[v] = true
+	}
+	if len(seen) != 10 {
+		t.Fatalf("choice without replacement repeats: %v", picks.Data)
+	}
+	weighted := g.Choice(3, shape, true, []float64{0, 1, 3})
+	if ng.Any(ng.Equal(weighted, ng.Arange[int](0, 1, 1)), nil, false).At(0) {
+		t.Fatal("index of zero weight was chosen")
+	}
+	if f := ng.Mean(ng.AsType[float64](weighted), nil, false).At(0); math.Abs(f-1.75) > 0.03 {
+		t.Fatalf("weighted choice mean %v", f)
+	}
+	if w := g.Choice(3, []int{2}, false, []float64{0, 1, 3}); w.At(0) == 0 || w.At(1) == 0 || w.At(0) == w.At(1) {
+		t.Fatalf("bad weighted choice without replacement %v", w.Data)
+	}
+	var valueErr *ng.ValueError
+	if _, err := g.TryChoice(3, []int{3}, false, []float64{0, 1, 3}); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError, got %v", err)
+	}
+
+	if p := g.Permutation(20); ng.Sum(p, nil, false).At(0) != 190 {
+		t.Fatalf("bad permutation %v", p.Data)
+	}
+	if _, err := g.TryPermutation(-1); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for a negative length, got %v", err)
+	}
+
+	// shuffling rows keeps every row intact
+	m := ng.Arange[int](0, 30, 1).Reshape([]int{10, 3})
+	ng.Shuffle(g, m, 0)
+	for r := 0; r < 10; r++ {
+		if m.At(3*r)%3 != 0 || m.At(3*r+1) != m.At(3*r)+1 || m.At(3*r+2) != m.At(3*r)+2 {
+			t.Fatalf("shuffle broke rows: %v", m.Data)
+		}
+	}
+	if ng.Sum(m, nil, false).At(0) != 435 {
+		t.Fatal("shuffle lost elements")
+	}
+	// along the last axis of a transposed view
+	mt := m.Transpose(nil)
+	ng.Shuffle(g, mt, -1)
+	if ng.Sum(m, nil, false).At(0) != 435 {
+		t.Fatal("shuffle of a view lost elements")
+	}
+}
+
+func TestRandomStreams(t *testing.T) {
+	// max is excluded
+	ints := ng.RandomInts[int]([]int{1000}, 0, 2)
+	if ng.Max(ints, nil, false).At(0) != 1 || ng.Min(ints, nil, false).At(0) != 0 {
+		t.Fatalf("RandomInts is not in [min, max): %v", ints.Data[:10])
+	}
+	if ng.Max(ng.Random[float32]([]int{1000}), nil, false).At(0) >= 1 {
+		t.Fatal("Random is not in [0, 1)")
+	}
+
+	// large arrays are drawn in parallel chunks, with a result
+	// that does not depend on the number of goroutines
+	shape := []int{3, 100000}
+	parallel := ng.NewGenerator(5).Normal(0, 1, shape)
+	procs := runtime.GOMAXPROCS(1)
+	serial := ng.NewGenerator(5).Normal(0, 1, shape)
+	runtime.GOMAXPROCS(procs)
+	if !ng.All(ng.Equal(parallel, serial), nil, false).At(0) {
+		t.Fatal("parallel generation depends on the number of goroutines")
+	}
+	// chunks use independent streams
+	first := parallel.Slice(ng.Span(0, 1), ng.Span(0, 100))
+	second := parallel.Slice(ng.Span(1, 2), ng.Span(0, 100))
+	if ng.All(ng.Equal(first, second), nil, false).At(0) {
+		t.Fatal("chunks repeat the same stream")
+	}
+	if m := ng.Mean(parallel, nil, false).At(0); math.Abs(m) > 0.01 {
+		t.Fatalf("mean of parallel normal draws %v", m)
+	}
+
+	gens := ng.NewGenerator(5).Split(2)
+	if ng.All(ng.Equal(gens[0].Uniform(0, 1, []int{10}), gens[1].Uniform(0, 1, []int{10})), nil, false).At(0) {
+		t.Fatal("split generators agree")
+	}
+
+	tn := ng.NewGenerator(5).TruncatedNormal(0, 1, 1, 2, []int{10000})
+	if ng.Min(tn, nil, false).At(0) < 1 || ng.Max(tn, nil, false).At(0) > 2 {
+		t.Fatal("truncated normal outside of its bounds")
+	}
+	// mean of the standard normal truncated to [1, 2]
+	if m := ng.Mean(tn, nil, false).At(0); math.Abs(m-1.3832) > 0.02 {
+		t.Fatalf("truncated normal mean %v", m)
+	}
+}
+
+func TestNpy(t *testing.T) {
+	a := ng.Arange[float64](0, 6, 1).Reshape([]int{2, 3})
+
+	var buf bytes.Buffer
+	if err := ng.SaveNpy(&buf, a); err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+	header := "{'descr': '<f8', 'fortran_order': False, 'shape': (2, 3), }"
+	if !bytes.HasPrefix(raw, []byte("\x93NUMPY\x01\x00")) || !bytes.Contains(raw, []byte(header)) {
+		t.Fatalf("bad npy header %q", raw[:80])
+	}
+	if len(raw) != 128+6*8 || raw[127] != '\n' {
+		t.Fatalf("data does not start at a multiple of 64 bytes: %d", len(raw))
+	}
+
+	// round trip with a conversion to float32
+	b, err := ng.LoadNpy[float32](bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ng.CheckShapesEqual(b.Shape, []int{2, 3}) || b.At(4) != 4 {
+		t.Fatalf("bad round trip %v %v", b.Shape, b.Data)
+	}
+
+	// headers around the 65535 byte limit of version 1.0, which holds
+	// the length of the padded header
+	for ndim := 21820; ndim < 21830; ndim++ {
+		shape := make([]int, ndim)
+		for i := range shape {
+			shape[i] = 1
+		}
+		var long bytes.Buffer
+		ng.SaveNpy(&long, ng.Ones[uint8](shape))
+		raw, prefix, length := long.Bytes(), 10, 0
+		if raw[6] == 1 {
+			length = int(binary.LittleEndian.Uint16(raw[8:]))
+		} else {
+			prefix, length = 12, int(binary.LittleEndian.Uint32(raw[8:]))
+		}
+		if (prefix+length)%64 != 0 || len(raw) != prefix+length+1 || raw[prefix+length-1] != '\n' {
+			t.Fatalf("bad header of version %d and length %d for %d dimensions", raw[6], length, ndim)
+		}
+		if l, err := ng.LoadNpy[uint8](bytes.NewReader(raw)); err != nil || l.Ndim != ndim {
+			t.Fatalf("bad round trip of %d dimensions: %v", ndim, err)
+		}
+	}
+
+	// a transposed view is F-ordered and written with fortran_order
+	buf.Reset()
+	ng.SaveNpy(&buf, a.Transpose(nil))
+	if !bytes.Contains(buf.Bytes(), []byte("'fortran_order': True, 'shape': (3, 2)")) {
+		t.Fatalf("transpose not written in fortran order: %q", buf.Bytes()[:80])
+	}
+	f, _ := ng.LoadNpy[float64](bytes.NewReader(buf.Bytes()))
+	if !f.F_ORDER || !ng.All(ng.Equal(f, a.Transpose(nil)), nil, false).At(0) {
+		t.Fatalf("bad fortran order round trip %v", f.Data)
+	}
+
+	// neither C nor F contiguous, written in row major order
+	rev := ng.Range{Start: ng.None, Stop: ng.None, Step: -1}
+	s := ng.Arange[int16](0, 12, 1).Reshape([]int{3, 4}).Slice(rev, ng.Span(1, 3))
+	buf.Reset()
+	ng.SaveNpy(&buf, s)
+	l, _ := ng.LoadNpy[int16](bytes.NewReader(buf.Bytes()))
+	if !ng.All(ng.Equal(l, s), nil, false).At(0) {
+		t.Fatalf("bad strided round trip %v", l.Data)
+	}
+
+	// a big endian version 2 file written by hand
+	dict := "{'descr': '>i4', 'fortran_order': False, 'shape': (3,), }"
+	dict += strings.Repeat(" ", 64-(12+len(dict)+1)%64) + "\n"
+	var big bytes.Buffer
+	big.WriteString("\x93NUMPY\x02\x00")
+	binary.Write(&big, binary.LittleEndian, uint32(len(dict)))
+	big.WriteString(dict)
+	binary.Write(&big, binary.BigEndian, []int32{-1, 2, 70000})
+	v, err := ng.LoadNpy[int64](&big)
+	if err != nil || v.At(0) != -1 || v.At(2) != 70000 {
+		t.Fatalf("bad big endian load %v %v", v, err)
+	}
+
+	var valueErr *ng.ValueError
+	if _, err := ng.LoadNpy[float64](strings.NewReader("not numpy data")); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError, got %v", err)
+	}
+
+	// headers declaring more data than the file holds, or than fits in an int
+	for _, shape := range []string{"(1000000000000,)", "(4294967296, 4294967296)"} {
+		var huge bytes.Buffer
+		huge.Write(npyHeaderBytes("{'descr': '<f8', 'fortran_order': False, 'shape': " + shape + ", }"))
+		huge.Write(make([]byte, 16))
+		if _, err := ng.LoadNpy[float64](&huge); err == nil {
+			t.Fatalf("expected an error for shape %s", shape)
+		}
+	}
+
+	// booleans round trip, and only load as bool from boolean data
+	var mbuf bytes.Buffer
+	mask := ng.Greater(a, ng.Arange[float64](2, 3, 1))
+	ng.SaveNpy(&mbuf, mask)
+	m, err := ng.LoadNpy[bool](&mbuf)
+	if err != nil || !ng.CheckShapesEqual(m.Shape, mask.Shape) || fmt.Sprint(m.Data) != fmt.Sprint(mask.Data) {
+		t.Fatalf("bad bool round trip %v %v", m, err)
+	}
+	if _, err := ng.LoadNpy[bool](bytes.NewReader(raw)); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError loading floats as bool, got %v", err)
+	}
+
+	// npz archive of several arrays
+	buf.Reset()
+	nw := ng.NewNpzWriter(&buf, true)
+	ng.WriteNpz(nw, "a", a)
+	ng.WriteNpz(nw, "mask", ng.Greater(a, ng.Arange[float64](2, 3, 1)))
+	if err := nw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	arrays, err := ng.LoadNpz[float64](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arrays) != 2 || !ng.All(ng.Equal(arrays["a"], a), nil, false).At(0) || arrays["mask"].At(3) != 1 || arrays["mask"].At(2) != 0 {
+		t.Fatalf("bad npz round trip %v", arrays)
+	}
+}
+
+// an NPY version 1 header holding dict, padded like numpy pads it
+func npyHeaderBytes(dict string) []byte {
+	dict += strings.Repeat(" ", 63-(10+len(dict))%64) + "\n"
+	b := append([]byte("\x93NUMPY\x01\x00"), byte(len(dict)), byte(len(dict)>>8))
+	return append(b, dict...)
+}
+
+func TestText(t *testing.T) {
+	csv := `# measurements
+time,temp,pressure
+0, 20.5, 1.0
+1, NA, 1.5  # sensor failure
+2, 21.5,
+
+3, 22.0, 2.5
+`
+	arr, err := ng.LoadText[float64](strings.NewReader(csv), ng.LoadTextOptions{
+		SkipRows: 2,
+		Missing:  []string{"NA"},
+		Fill:     math.NaN(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ng.CheckShapesEqual(arr.Shape, []int{4, 3}) || arr.At(5) != 1.5 || !math.IsNaN(arr.At(4)) || !math.IsNaN(arr.At(8)) {
+		t.Fatalf("bad loaded csv %v %v", arr.Shape, arr.Data)
+	}
+
+	// column selection, whitespace delimiter and integer parsing
+	cols, err := ng.LoadText[int32](strings.NewReader("1 2 3\n4  5 6\n"), ng.LoadTextOptions{Delimiter: ' ', Columns: []int{-1, 0}})
+	if err != nil || !ng.CheckShapesEqual(cols.Shape, []int{2, 2}) || cols.At(0) != 3 || cols.At(3) != 4 {
+		t.Fatalf("bad column selection %v %v", cols, err)
+	}
+
+	var valueErr *ng.ValueError
+	if _, err := ng.LoadText[int](strings.NewReader("1,2\n3,x\n"), ng.LoadTextOptions{}); !errors.As(err, &valueErr) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected ValueError on line 2, got %v", err)
+	}
+	if _, err := ng.LoadText[int](strings.NewReader("1,2\n3\n"), ng.LoadTextOptions{}); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for ragged rows, got %v", err)
+	}
+
+	// round trip of a transposed view
+	var buf bytes.Buffer
+	a := ng.Arange[float64](0, 6, 1).Reshape([]int{2, 3}).Transpose(nil)
+	if err := ng.SaveText(&buf, a, ng.SaveTextOptions{Delimiter: ';', Format: "%.2f", Header: "x;y"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "# x;y\n0.00;3.00\n1.00;4.00\n") {
+		t.Fatalf("bad saved text %q", buf.String())
+	}
+	back, err := ng.LoadText[float64](&buf, ng.LoadTextOptions{Delimiter: ';'})
+	if err != nil || !ng.All(ng.Equal(back, a), nil, false).At(0) {
+		t.Fatalf("bad text round trip %v %v", back, err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	a := ng.Arange[float64](0, 6, 1).Reshape([]int{2, 3})
+	if got := a.String(); got != "[[0. 1. 2.]\n [3. 4. 5.]]" {
+		t.Fatalf("bad String %q", got)
+	}
+	if got := fmt.Sprintf("%.2f", ng.Arange[float64](0, 3, 1)); got != "[0.00 1.00 2.00]" {
+		t.Fatalf("bad %%.2f %q", got)
+	}
+	if got := fmt.Sprintf("%e", ng.Arange[float64](1, 4, 1)); got != "[1.e+00 2.e+00 3.e+00]" {
+		t.Fatalf("bad %%e %q", got)
+	}
+	third := ng.Apply(ng.Arange[float64](1, 4, 1), func(v float64) float64 { return v / 3 })
+	if got := fmt.Sprint(third); got != "[0.33333333 0.66666667 1.        ]" {
+		t.Fatalf("bad trailing zeros %q", got)
+	}
+	if got := fmt.Sprint(ng.Arange[int](-1, 2, 1)); got != "[-1  0  1]" {
+		t.Fatalf("bad ints %q", got)
+	}
+	if got := fmt.Sprintf("%d", a); got != "%!d(*ndgo.Array)" {
+		t.Fatalf("bad verb %q", got)
+	}
+	if got := fmt.Sprintf("%d", ng.Arange[int](-1, 2, 1)); got != "[-1  0  1]" {
+		t.Fatalf("bad %%d %q", got)
+	}
+	if got := fmt.Sprintf("%x", ng.Arange[uint8](9, 12, 1)); got != "[9 a b]" {
+		t.Fatalf("bad %%x %q", got)
+	}
+	if got := fmt.Sprintf("%o", ng.Arange[int](7, 9, 1)); got != "[ 7 10]" {
+		t.Fatalf("bad %%o %q", got)
+	}
+	if got := fmt.Sprintf("%d", ng.Wrap([]bool{true, false}, []int{2})); got != "%!d(*ndgo.Array)" {
+		t.Fatalf("bad verb for bools %q", got)
+	}
+
+	// summarisation and wrapping
+	big := ng.Arange[int](0, 2000, 1).Reshape([]int{40, 50})
+	want := "[[   0    1    2 ...   47   48   49]\n [  50   51   52 ...   97   98   99]\n"
+	if got := big.String(); !strings.HasPrefix(got, want) || !strings.Contains(got, "\n ...\n") {
+		t.Fatalf("bad summary %q", got)
+	}
+	opts := ng.DefaultPrintOptions()
+	opts.LineWidth = 12
+	var buf bytes.Buffer
+	if err := ng.Fprint(&buf, ng.Arange[int](0, 6, 1), opts); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[0 1 2 3 4\n 5]" {
+		t.Fatalf("bad wrapping %q", buf.String())
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	// a transposed view, encoded in its logical order
+	a := ng.Arange[float64](0, 6, 1).Reshape([]int{2, 3}).Transpose(nil)
+	nested, err := json.Marshal(a)
+	if err != nil || string(nested) != "[[0,3],[1,4],[2,5]]" {
+		t.Fatalf("bad nested json %s %v", nested, err)
+	}
+	compact, err := json.Marshal(ng.CompactJSON[float64]{Array: a})
+	if err != nil || string(compact) != `{"shape":[3,2],"data":[0,3,1,4,2,5]}` {
+		t.Fatalf("bad compact json %s %v", compact, err)
+	}
+	for _, b := range [][]byte{nested, compact} {
+		var back ng.Array[float64]
+		if err := json.Unmarshal(b, &back); err != nil || !ng.All(ng.Equal(&back, a), nil, false).At(0) {
+			t.Fatalf("bad json round trip of %s: %v %v", b, &back, err)
+		}
+	}
+
+	var ints ng.Array[int8]
+	if err := json.Unmarshal([]byte("[[1,2],[3]]"), &ints); err == nil {
+		t.Fatal("expected an error for ragged lists")
+	}
+	if err := json.Unmarshal([]byte("[1.5]"), &ints); err == nil {
+		t.Fatal("expected an error for a float in an int array")
+	}
+	var valueErr *ng.ValueError
+	if _, err := json.Marshal(ng.Apply(a, func(v float64) float64 { return v / 0 })); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for NaN, got %v", err)
+	}
+
+	// binary and gob, of a struct holding a mask
+	b, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back ng.Array[float64]
+	if err := back.UnmarshalBinary(b); err != nil || !back.C_ORDER || !ng.All(ng.Equal(&back, a), nil, false).At(0) {
+		t.Fatalf("bad binary round trip %v %v", &back, err)
+	}
+	var wrong ng.Array[float32]
+	if err := wrong.UnmarshalBinary(b); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for another dtype, got %v", err)
+	}
+	if err := back.UnmarshalBinary(b[:len(b)-1]); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for truncated data, got %v", err)
+	}
+
+	type message struct {
+		Name string
+		Mask *ng.Array[bool]
+	}
+	var buf bytes.Buffer
+	mask := ng.Greater(a, ng.Arange[float64](2, 3, 1))
+	if err := gob.NewEncoder(&buf).Encode(message{"mask", mask}); err != nil {
+		t.Fatal(err)
+	}
+	var got message
+	if err := gob.NewDecoder(&buf).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "mask" || !ng.CheckShapesEqual(got.Mask.Shape, []int{3, 2}) || fmt.Sprint(got.Mask.Data) != "[false true false true false true]" {
+		t.Fatalf("bad gob round trip %+v", got)
+	}
+}
+
+// builds a safetensors file from its header and data
+func safetensorsFile(header string, data []byte) []byte {
+	b := binary.LittleEndian.AppendUint64(nil, uint64(len(header)))
+	return append(append(b, header...), data...)
+}
+
+func TestSafetensors(t *testing.T) {
+	weights := ng.Arange[float32](0, 6, 1).Reshape([]int{2, 3}).Transpose(nil)
+	steps := ng.Arange[int64](-2, 2, 1)
+	sw := safetensors.NewWriter(map[string]string{"format": "pt"})
+	if err := safetensors.Add(sw, "weights", weights); err != nil {
+		t.Fatal(err)
+	}
+	if err := safetensors.Add(sw, "steps", steps); err != nil {
+		t.Fatal(err)
+	}
+	if err := safetensors.Add(sw, "steps", steps); err == nil {
+		t.Fatal("expected an error for a repeated name")
+	}
+	var buf bytes.Buffer
+	if _, err := sw.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	b := buf.Bytes()
+	f, err := safetensors.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Metadata["format"] != "pt" || strings.Join(f.Names(), ",") != "steps,weights" {
+		t.Fatalf("bad file %v %v", f.Metadata, f.Names())
+	}
+	w, err := safetensors.Tensor[float32](f, "weights")
+	if err != nil || !ng.All(ng.Equal(w, weights), nil, false).At(0) {
+		t.Fatalf("bad weights %v %v", w, err)
+	}
+	// the tensor shares its memory with the file
+	w.Set(0, 42)
+	if again, _ := safetensors.Tensor[float32](f, "weights"); again.At(0) != 42 {
+		t.Fatal("expected a zero-copy tensor")
+	}
+	s, err := safetensors.TensorAs[float64](f, "steps")
+	if err != nil || fmt.Sprint(s.Data) != "[-2 -1 0 1]" {
+		t.Fatalf("bad converted steps %v %v", s, err)
+	}
+	var valueErr *ng.ValueError
+	if _, err := safetensors.Tensor[float64](f, "steps"); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for another dtype, got %v", err)
+	}
+
+	// half precision, 1, -2 and 0.5 in F16 and 1.5 in BF16
+	half := safetensorsFile(`{"h":{"dtype":"F16","shape":[3],"data_offsets":[0,6]},"b":{"dtype":"BF16","shape":[],"data_offsets":[6,8]}}`,
+		[]byte{0x00, 0x3c, 0x00, 0xc0, 0x00, 0x38, 0xc0, 0x3f})
+	if f, err = safetensors.Parse(half); err != nil {
+		t.Fatal(err)
+	}
+	h, _ := safetensors.TensorAs[float32](f, "h")
+	bf, _ := safetensors.TensorAs[float32](f, "b")
+	if fmt.Sprint(h.Data) != "[1 -2 0.5]" || !ng.CheckShapesEqual(bf.Shape, []int{1}) || bf.At(0) != 1.5 {
+		t.Fatalf("bad half precision %v %v", h, bf)
+	}
+
+	for _, header := range []string{
+		`{"a":{"dtype":"F32","shape":[2],"data_offsets":[0,8]},"b":{"dtype":"F32","shape":[1],"data_offsets":[4,8]}}`,
+		`{"a":{"dtype":"F32","shape":[3],"data_offsets":[0,12]}}`,
+		`{"a":{"dtype":"F32","shape":[1],"data_offsets":[0,8]}}`,
+		`{"a":{"dtype":"X9","shape":[1],"data_offsets":[0,8]}}`,
+		`{"a":{"dtype":"F32","shape":[4294967296,4294967296],"data_offsets":[0,0]}}`,
+	} {
+		if _, err := safetensors.Parse(safetensorsFile(header, make([]byte, 8))); !errors.As(err, &valueErr) {
+			t.Fatalf("expected ValueError for %s, got %v", header, err)
+		}
+	}
+}
+
+func TestMemmap(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.npy")
+	m, err := ng.OpenMemmap[float32](path, []int{2, 3}, ng.MemmapCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ng.Apply_(m.Array, func(float32) float32 { return 1 })
+	m.Set(5, 7)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is a regular npy file
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ng.LoadNpy[float32](f)
+	f.Close()
+	if err != nil || fmt.Sprint(loaded.Data) != "[1 1 1 1 1 7]" {
+		t.Fatalf("bad npy file %v %v", loaded, err)
+	}
+
+	// copy on write changes the array but not the file
+	c, err := ng.OpenMemmap[float32](path, nil, ng.MemmapCopyOnWrite)
+	if err != nil || !ng.CheckShapesEqual(c.Shape, []int{2, 3}) {
+		t.Fatalf("bad copy on write map %v %v", c, err)
+	}
+	c.Set(0, 3)
+	c.Close()
+	r, err := ng.OpenMemmap[float32](path, nil, ng.MemmapReadOnly)
+	if err != nil || r.At(0) != 1 || r.At(5) != 7 {
+		t.Fatalf("bad read only map %v %v", r, err)
+	}
+	r.Close()
+
+	var valueErr *ng.ValueError
+	if _, err := ng.OpenMemmap[float64](path, nil, ng.MemmapReadOnly); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for another dtype, got %v", err)
+	}
+	var shapeErr *ng.ShapeError
+	if _, err := ng.OpenMemmap[float32](path, []int{3, 2}, ng.MemmapReadOnly); !errors.As(err, &shapeErr) {
+		t.Fatalf("expected ShapeError for another shape, got %v", err)
+	}
+
+	// column major npy files map to a transposed view
+	a := ng.Arange[int16](0, 6, 1).Reshape([]int{2, 3}).Transpose(nil)
+	fpath := filepath.Join(dir, "f.npy")
+	f, _ = os.Create(fpath)
+	ng.SaveNpy(f, a)
+	f.Close()
+	fm, err := ng.OpenMemmap[int16](fpath, nil, ng.MemmapReadOnly)
+	if err != nil || !ng.All(ng.Equal(fm.Array, a), nil, false).At(0) {
+		t.Fatalf("bad fortran map %v %v", fm, err)
+	}
+	fm.Close()
+
+	// raw files, read and written in place
+	raw := filepath.Join(dir, "a.raw")
+	os.WriteFile(raw, []byte{1, 2, 3, 4, 5, 6, 7}, 0o644)
+	rw, err := ng.OpenMemmap[uint8](raw, []int{3, 2}, ng.MemmapReadWrite)
+	if err != nil || rw.At(5) != 6 {
+		t.Fatalf("bad raw map %v %v", rw, err)
+	}
+	rw.Set(0, 9)
+	if err := rw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	rw.Close()
+	if b, _ := os.ReadFile(raw); b[0] != 9 {
+		t.Fatalf("write to the map not in the file %v", b)
+	}
+	if _, err := ng.OpenMemmap[uint8](raw, []int{4, 2}, ng.MemmapReadOnly); !errors.As(err, &shapeErr) {
+		t.Fatalf("expected ShapeError for a short file, got %v", err)
+	}
+
+	// a header which is not padded leaves the data misaligned
+	dict := "{'descr': '<f4', 'fortran_order': False, 'shape': (2,), } \n"
+	unpadded := append([]byte("\x93NUMPY\x01\x00"), byte(len(dict)), 0)
+	unpadded = append(append(unpadded, dict...), make([]byte, 8)...)
+	upath := filepath.Join(dir, "unpadded.npy")
+	os.WriteFile(upath, unpadded, 0o644)
+	if _, err := ng.OpenMemmap[float32](upath, nil, ng.MemmapReadOnly); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for misaligned data, got %v", err)
+	}
+}
+
+func TestConstructors(t *testing.T) {
+	if got := fmt.Sprint(ng.Full([]int{2, 2}, int8(7)).Data); got != "[7 7 7 7]" {
+		t.Fatalf("bad Full %s", got)
+	}
+	a := ng.Arange[float32](0, 6, 1).Reshape([]int{2, 3}).Transpose(nil)
+	if z, o := ng.ZerosLike(a), ng.OnesLike(a); !ng.CheckShapesEqual(z.Shape, []int{3, 2}) || z.At(4) != 0 || o.At(4) != 1 {
+		t.Fatalf("bad ZerosLike or OnesLike %v %v", z, o)
+	}
+	if got := fmt.Sprint(ng.Eye[int](2, 3, 1)); got != "[[0 1 0]\n [0 0 1]]" {
+		t.Fatalf("bad Eye %q", got)
+	}
+	if got := fmt.Sprint(ng.Eye[int](3, 2, -1).Data); got != "[0 0 1 0 0 1]" {
+		t.Fatalf("bad Eye below the diagonal %s", got)
+	}
+	if id := ng.Identity[float64](3); ng.Trace(id, 0, 0, 1).At(0) != 3 || ng.Sum(id, nil, false).At(0) != 3 {
+		t.Fatalf("bad Identity %v", id)
+	}
+
+	lin, step := ng.Linspace[float64](2, 3, 5, true)
+	if step != 0.25 || fmt.Sprint(lin.Data) != "[2 2.25 2.5 2.75 3]" {
+		t.Fatalf("bad Linspace %v %v", lin, step)
+	}
+	if lin, step = ng.Linspace[float64](2, 3, 4, false); step != 0.25 || lin.At(3) != 2.75 {
+		t.Fatalf("bad Linspace without endpoint %v %v", lin, step)
+	}
+	if _, step = ng.Linspace[float64](2, 3, 1, true); !math.IsNaN(step) {
+		t.Fatalf("expected a NaN step for a single sample, got %v", step)
+	}
+	assertClose(t, "Logspace", ng.Logspace[float64](0, 3, 4, true, 10), ng.Apply(ng.Arange[float64](0, 4, 1), func(v float64) float64 { return math.Pow(10, v) }), 1e-9)
+	geom := ng.Geomspace[float64](-1, -1000, 4, true)
+	if fmt.Sprint(geom.Data) != "[-1 -10 -100 -1000]" {
+		t.Fatalf("bad Geomspace %v", geom)
+	}
+	var valueErr *ng.ValueError
+	if _, err := ng.TryGeomspace[float64](-1, 10, 3, true); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for a sign change, got %v", err)
+	}
+
+	// descending ranges and steps that do not drift
+	if got := fmt.Sprint(ng.Arange[int](5, 0, -2).Data); got != "[5 3 1]" {
+		t.Fatalf("bad descending Arange %s", got)
+	}
+	if r := ng.Arange[float64](0, 1, 0.1); r.Totalsize != 10 || r.At(9) != 9*0.1 {
+		t.Fatalf("bad float Arange %v", r)
+	}
+	if r := ng.Arange[int](0, 0, 1); r.Totalsize != 0 {
+		t.Fatalf("expected an empty range, got %v", r)
+	}
+	if _, err := ng.TryArange[int](0, 3, 0); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for a zero step, got %v", err)
+	}
+	if _, err := ng.TryArange[float64](0, 1e300, 1); !errors.As(err, &valueErr) {
+		t.Fatalf("expected ValueError for a length overflowing int, got %v", err)
+	}
+}
@@ -3,46 +3,34 @@ package ndgo
 import (
 	"fmt"
 	"math"
-	"math/rand"
+	"math/rand/v2"
+	"os"
 )
 
-const SIZEOF_FLOAT32 int = 4
 const PARALLEL_BOUNDARY int = 1e5
 
-// holds all nD indices of the array
-type ArrayIndices struct {
-	Indices [][]int
-	Count   int
-}
-
-// holds the 1D indices corresponding to nD indices
-type LinearIndices struct {
-	Indices []int
-	Count   int
-}
-
-type Array struct {
-	Data        []float32
+type Array[T Elem] struct {
+	Data        []T
 	Shape       []int
 	Strides     []int
 	Backstrides []int
+	Offset      int // byte offset of the first element in Data
 	Ndim        int
 	Itemsize    int
 	Totalsize   int
-	Idxs        *ArrayIndices
-	Lidxs       *LinearIndices
 	C_ORDER     bool
 	F_ORDER     bool
 }
 
-type ArrayFunc func(float32) float32
-type binOpFunc func(*Array, *Array, *Array, int)
+type ArrayFunc[T Elem] func(T) T
+type BinaryFunc[T Numeric] func(T, T) T
+type binOpFunc[T, R Elem] func(a, b *Array[T], res *Array[R], start, end int)
 
 // private functions
 // ------------------------------------------------------------
 
 // strides for an Array
-func (arr *Array) recalculateStrides() {
+func (arr *Array[T]) recalculateStrides() {
 	arr.Strides[arr.Ndim-1] = arr.Itemsize
 	for i := arr.Ndim - 2; i >= 0; i-- {
 		arr.Strides[i] = arr.Strides[i+1] * arr.Shape[i+1]
@@ -50,80 +38,64 @@ func (arr *Array) recalculateStrides() {
 }
 
 // backstrides for an Array
-func (arr *Array) recalculateBackstrides() {
+func (arr *Array[T]) recalculateBackstrides() {
 	for i := arr.Ndim - 1; i >= 0; i-- {
 		arr.Backstrides[i] = -1 * arr.Strides[i] * (arr.Shape[i] - 1)
 	}
 }
 
-// nD indices for an Array
-func (arr *Array) createArrayIndices() {
-	arr.Idxs = arrayIndicesFromShape(arr.Shape)
-}
-
-func arrayIndicesFromShape(shape []int) *ArrayIndices {
-	ndim := len(shape)
-	totalsize := 1
-	for _, v := range shape {
-		totalsize *= v
-	}
+// setArrayFlags sets flags for array, an array is C_ORDER (or F_ORDER)
+// only when its elements are contiguous in row (or column) major order
+func (arr *Array[T]) setArrayFlags() {
+	arr.C_ORDER, arr.F_ORDER = true, true
 
-	idxs := &ArrayIndices{
-		Count:   totalsize,
-		Indices: make([][]int, totalsize),
-	}
-
-	for i := range idxs.Indices {
-		idxs.Indices[i] = make([]int, ndim)
+	expected := arr.Itemsize
+	for i := arr.Ndim - 1; i >= 0; i-- {
+		if arr.Shape[i] != 1 && arr.Strides[i] != expected {
+			arr.C_ORDER = false
+			break
+		}
+		expected *= arr.Shape[i]
 	}
 
-	currentIndex := make([]int, ndim)
-	for i := 0; i < idxs.Count; i++ {
-		copy(idxs.Indices[i], currentIndex)
-
-		// increment the index
-		for j := ndim - 1; j >= 0; j-- {
-			if currentIndex[j]+1 < shape[j] {
-				currentIndex[j]++
-				break
-			}
-			currentIndex[j] = 0
+	expected = arr.Itemsize
+	for i := 0; i < arr.Ndim; i++ {
+		if arr.Shape[i] != 1 && arr.Strides[i] != expected {
+			arr.F_ORDER = false
+			break
 		}
+		expected *= arr.Shape[i]
 	}
-
-	return idxs
 }
 
-// 1D equivalent of nD indices
-func (arr *Array) createLinearIndices() {
-	arr.Lidxs = &LinearIndices{
-		Count:   arr.Totalsize,
-		Indices: make([]int, arr.Totalsize),
+// view creates a new Array header over the same Data, with
+// the given shape, strides and byte offset
+func (arr *Array[T]) view(shape, strides []int, offset int) *Array[T] {
+	ndim := len(shape)
+	res := &Array[T]{
+		Data:        arr.Data,
+		Ndim:        ndim,
+		Shape:       make([]int, ndim),
+		Strides:     make([]int, ndim),
+		Backstrides: make([]int, ndim),
+		Offset:      offset,
+		Itemsize:    arr.Itemsize,
 	}
+	copy(res.Shape, shape)
+	copy(res.Strides, strides)
 
-	for i := 0; i < arr.Totalsize; i++ {
-		arr.Lidxs.Indices[i] = 0
-		for j := 0; j < arr.Ndim; j++ {
-			arr.Lidxs.Indices[i] += (arr.Idxs.Indices[i][j] * arr.Strides[j]) / SIZEOF_FLOAT32
-		}
+	res.Totalsize = 1
+	for _, v := range shape {
+		res.Totalsize *= v
 	}
-}
-
-// setArrayFlags sets flags for array
-func (arr *Array) setArrayFlags() {
-	arr.C_ORDER = arr.Strides[arr.Ndim-1] == arr.Itemsize
-	arr.F_ORDER = arr.Strides[0] == arr.Itemsize
-}
 
-func getRandom(min, max float32) float32 {
-	return min + rand.Float32()*(max-min)
-}
+	res.recalculateBackstrides()
+	res.setArrayFlags()
 
-func getRandomInt(min, max int) int {
-	return min + rand.Intn(max-min+1)
+	return res
 }
 
-func checkShapeCompatible(arr *Array, shape []int) bool {
+func checkShapeCompatible[T Elem](arr *Array[T], shape []int) bool {
 	var size_new int = 1
 	for _, value := range shape {
 		size_new *= value
@@ -135,18 +107,25 @@ func checkShapeCompatible(arr *Array, shape []int) bool {
 // Public functions
 // ------------------------------------------------------
 
-func NewArrayFromShape(shape []int) *Array {
+// TryNewArrayFromShape creates a zeroed Array with elements of type T,
+// e.g. TryNewArrayFromShape[float64]([]int{2, 3})
+func TryNewArrayFromShape[T Elem](shape []int) (*Array[T], error) {
 	ndim := len(shape)
 	if ndim <= 0 {
-		panic(fmt.Sprintf("Cannot initialize Array of dimensions %d", ndim))
+		return nil, &ShapeError{Op: "NewArrayFromShape", Shape: shape, Msg: fmt.Sprintf("cannot initialize Array of dimensions %d", ndim)}
+	}
+	for _, v := range shape {
+		if v < 0 {
+			return nil, &ShapeError{Op: "NewArrayFromShape", Shape: shape, Msg: "negative dimensions are not allowed"}
+		}
 	}
 
-	arr := &Array{
+	arr := &Array[T]{
 		Ndim:        ndim,
 		Shape:       make([]int, ndim),
 		Strides:     make([]int, ndim),
 		Backstrides: make([]int, ndim),
-		Itemsize:    4, // size of float32
+		Itemsize:    sizeof[T](),
 	}
 
 	arr.Totalsize = 1
@@ -155,143 +134,178 @@ func NewArrayFromShape(shape []int) *Array {
 		arr.Totalsize *= shape[i]
 	}
 
-	arr.Data = make([]float32, arr.Totalsize)
+	arr.Data = make([]T, arr.Totalsize)
 	arr.recalculateStrides()
 	arr.recalculateBackstrides()
-	arr.createArrayIndices()
-	arr.createLinearIndices()
 	arr.setArrayFlags()
 
-	return arr
+	return arr, nil
 }
 
-// returns the element at the linear index specified by i
-func (arr *Array) At(i int) float32 {
-	return arr.Data[arr.Lidxs.Indices[i]]
+// NewArrayFromShape is like TryNewArrayFromShape but panics on error
+func NewArrayFromShape[T Elem](shape []int) *Array[T] {
+	return must(TryNewArrayFromShape[T](shape))
 }
 
-// sets the element at linear index i, by the given value
-func (arr *Array) Set(i int, value float32) {
-	arr.Data[arr.Lidxs.Indices[i]] = value
+/*
+TryWrap creates a C-contiguous Array of the given shape over data without
+copying it, so the Array and data share their elements. len(data) must
+be the size of the shape.
+*/
+func TryWrap[T Elem](data []T, shape []int) (*Array[T], error) {
+	if len(shape) == 0 {
+		return nil, &ShapeError{Op: "Wrap", Shape: shape, Msg: "cannot initialize Array of dimensions 0"}
+	}
+	strides := make([]int, len(shape))
+	stride := sizeof[T]()
+	for i := len(shape) - 1; i >= 0; i-- {
+		if shape[i] < 0 {
+			return nil, &ShapeError{Op: "Wrap", Shape: shape, Msg: "negative dimensions are not allowed"}
+		}
+		strides[i] = stride
+		stride *= shape[i]
+	}
+	if ShapeSize(shape) != len(data) {
+		return nil, &ShapeError{Op: "Wrap", Shape: shape, Msg: fmt.Sprintf("cannot wrap %d values", len(data))}
+	}
+
+	arr := &Array[T]{Data: data, Itemsize: sizeof[T]()}
+	return arr.view(shape, strides, 0), nil
 }
 
-// Random creates a random array of floats from shape,
-// values will be in range [0.0, 1.0]
-func Random(shape []int) *Array {
-	arr := NewArrayFromShape(shape)
-	for i := range arr.Data {
-		arr.Set(i, getRandom(0, 1))
-	}
-	return arr
+// Wrap is like TryWrap but panics on error
+func Wrap[T Elem](data []T, shape []int) *Array[T] {
+	return must(TryWrap(data, shape))
 }
 
-// RandomInts creates a random int array from shape, values will be in range [min, max)
-func RandomInts(shape []int, min, max int) *Array {
-	if min >= max {
-		panic(fmt.Sprintf("Value of min %d must be less than value of max %d", min, max))
+// position in Data of the element at linear (row major) index i
+func (arr *Array[T]) dataIndex(i int) int {
+	base := arr.Offset / arr.Itemsize
+	// fast path for contiguous arrays
+	if arr.C_ORDER {
+		return base + i
 	}
-	arr := NewArrayFromShape(shape)
-	for i := range arr.Data {
-		arr.Set(i, float32(getRandomInt(min, max)))
+
+	index := base
+	for d := arr.Ndim - 1; d >= 0; d-- {
+		index += (i % arr.Shape[d]) * (arr.Strides[d] / arr.Itemsize)
+		i /= arr.Shape[d]
 	}
-	return arr
+	return index
 }
 
-// Arange creates an array with values from start to end (exclusive) with the given step
-func Arange(start, end, step float32) *Array {
-	if start >= end {
-		panic("Start value should be less than end value")
-	}
-	if step <= 0 {
-		panic("Step value should be greater than 0")
-	}
+// returns the element at the linear index specified by i
+func (arr *Array[T]) At(i int) T {
+	return arr.Data[arr.dataIndex(i)]
+}
 
-	length := int(math.Ceil(float64((end - start) / step)))
-	shape := []int{length}
-	arr := NewArrayFromShape(shape)
+// sets the element at linear index i, by the given value
+func (arr *Array[T]) Set(i int, value T) {
+	arr.Data[arr.dataIndex(i)] = value
+}
 
-	curr := start
-	for i := range arr.Data {
-		arr.Set(i, curr)
-		curr += step
-	}
+// TryRandom creates a random array of floats from shape, values will
+// be in range [0.0, 1.0). Use a Generator for reproducible arrays.
+func TryRandom[T Float](shape []int) (*Array[T], error) {
+	return generate(globalGenerator(), shape, unitFloat[T])
+}
 
-	return arr
+// Random is like TryRandom but panics on error
+func Random[T Float](shape []int) *Array[T] {
+	return must(TryRandom[T](shape))
 }
 
-func traverseHelper(data []float32, shape, strides, backstrides []int, ndim, depth, offset int) int {
-	// we are at the last dimension
-	if depth == ndim-1 {
-		fmt.Printf("[")
-		for i := 0; i < shape[ndim-1]; i++ {
-			if i != shape[ndim-1]-1 {
-				fmt.Printf("%.3f ", data[offset])
-			} else {
-				fmt.Printf("%.3f", data[offset])
-			}
-			if i != shape[ndim-1]-1 {
-				offset += (strides[ndim-1] / SIZEOF_FLOAT32)
-			}
-		}
-		fmt.Printf("]")
-		// backstep
-		offset += (backstrides[ndim-1] / SIZEOF_FLOAT32)
-		return offset
+// TryRandomInts creates a random int array from shape, values will be in range [min, max)
+func TryRandomInts[T Numeric](shape []int, min, max int) (*Array[T], error) {
+	if min >= max {
+		return nil, &ValueError{Op: "RandomInts", Msg: fmt.Sprintf("value of min %d must be less than value of max %d", min, max)}
 	}
+	return generate(globalGenerator(), shape, func(r *rand.Rand) T {
+		return T(min + r.IntN(max-min))
+	})
+}
+
+// RandomInts is like TryRandomInts but panics on error
+func RandomInts[T Numeric](shape []int, min, max int) *Array[T] {
+	return must(TryRandomInts[T](shape, min, max))
+}
 
-	if depth == 0 {
-		fmt.Printf("[\n")
-	} else {
-		fmt.Printf("[")
+/*
+TryArange creates an array with values from start to end (exclusive)
+with the given step, which may be negative for descending values. The
+values are computed as start + i*step so that float steps do not drift,
+and an empty range gives an array of shape [0]. A range whose length
+overflows int is a ValueError.
+*/
+func TryArange[T Numeric](start, end, step T) (*Array[T], error) {
+	if step == 0 {
+		return nil, &ValueError{Op: "Arange", Msg: "step value must not be 0"}
 	}
-	offset = traverseHelper(data, shape, strides, backstrides, ndim, depth+1, offset)
-	for i := 0; i < shape[depth]-1; i++ {
-		offset += (strides[depth] / SIZEOF_FLOAT32)
-		fmt.Println()
-		offset = traverseHelper(data, shape, strides, backstrides, ndim, depth+1, offset)
+
+	length := math.Ceil((float64(end) - float64(start)) / float64(step))
+	if math.IsNaN(length) || math.IsInf(length, 0) {
+		return nil, &ValueError{Op: "Arange", Msg: fmt.Sprintf("range from %v to %v by %v is not finite", start, end, step)}
 	}
-	if depth == 0 {
-		fmt.Printf("]\n")
-	} else {
-		fmt.Printf("]")
+	// the length is checked in float64, converting it to int first
+	// would overflow
+	if length > float64(math.MaxInt/sizeof[T]()) {
+		return nil, &ValueError{Op: "Arange", Msg: fmt.Sprintf("range from %v to %v by %v has %g elements, too many for an Array", start, end, step, length)}
 	}
-	offset += (backstrides[depth] / SIZEOF_FLOAT32)
-	if depth != 0 {
-		fmt.Println()
+	arr := NewArrayFromShape[T]([]int{max(int(length), 0)})
+	for i := range arr.Data {
+		arr.Data[i] = start + T(i)*step
 	}
-	return offset
+
+	return arr, nil
+}
+
+// Arange is like TryArange but panics on error
+func Arange[T Numeric](start, end, step T) *Array[T] {
+	return must(TryArange(start, end, step))
 }
 
-// prints the array similar to numpy
-func PrettyPrint(arr *Array) {
-	traverseHelper(arr.Data, arr.Shape, arr.Strides, arr.Backstrides, arr.Ndim, 0, 0)
+// prints the array to stdout similar to numpy, with the DefaultPrintOptions
+func PrettyPrint[T Elem](arr *Array[T]) {
+	Fprint(os.Stdout, arr, DefaultPrintOptions())
+	fmt.Println()
 }
 
-// can be parallelized
-func pApply(arr *Array, fun ArrayFunc) {
-	for i := 0; i < arr.Totalsize; i++ {
-		arr.Set(i, fun(arr.Data[i]))
+// applies fun to the elements of arr in-place, in row major order, so
+// fun may keep state between calls
+func pApply[T Elem](arr *Array[T], fun ArrayFunc[T]) {
+	for it := arr.Iter(); it.Next(); {
+		arr.Data[it.Index()] = fun(arr.Data[it.Index()])
 	}
 }
 
+// mapArray applies fun to all the elements of arr and
+// returns the results as a new C-contiguous Array
+func mapArray[T, R Elem](arr *Array[T], fun func(T) R) *Array[R] {
+	res := NewArrayFromShape[R](arr.Shape)
+	i := 0
+	for it := arr.Iter(); it.Next(); i++ {
+		res.Data[i] = fun(arr.Data[it.Index()])
+	}
+	return res
+}
+
 // applies an ArrayFunc to all the elements of an Array
 // and returns a new Array
-func Apply(arr *Array, fun ArrayFunc) *Array {
+func Apply[T Elem](arr *Array[T], fun ArrayFunc[T]) *Array[T] {
 	if fun == nil {
-		panic("ApplyError: function argument nil/missing.")
+		panic(&ValueError{Op: "Apply", Msg: "function argument nil/missing"})
 	}
 
-	res := NewArrayFromShape(arr.Shape)
-	res.FromValues(arr.Data)
+	res := arr.Copy()
 	pApply(res, fun)
 	return res
 }
 
 // applies an ArrayFunc to all the elements of an Array
 // in-place, and DOES NOT return a new Array
-func Apply_(arr *Array, fun ArrayFunc) {
+func Apply_[T Elem](arr *Array[T], fun ArrayFunc[T]) {
 	if fun == nil {
-		panic("ApplyError: function argument nil/missing.")
+		panic(&ValueError{Op: "Apply", Msg: "function argument nil/missing"})
 	}
 
 	pApply(arr, fun)
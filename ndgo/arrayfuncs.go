@@ -5,66 +5,66 @@ import (
 )
 
 // -1*x for all x in an Array
-func neg() ArrayFunc {
-	return func(x float32) float32 {
-		ans := -1 * x
+func neg[T Numeric]() ArrayFunc[T] {
+	return func(x T) T {
+		ans := -x
 		return ans
 	}
 }
 
 // e**x for all x in an Array
-func exp() ArrayFunc {
-	return func(x float32) float32 {
+func exp[T Numeric]() ArrayFunc[T] {
+	return func(x T) T {
 		ans := math.Exp(float64(x))
-		return float32(ans)
+		return T(ans)
 	}
 }
 
 // scale the Array using a scalar
-func log() ArrayFunc {
-	return func(x float32) float32 {
+func log[T Numeric]() ArrayFunc[T] {
+	return func(x T) T {
 		ans := math.Log(float64(x))
-		return float32(ans)
+		return T(ans)
 	}
 }
 
 // sin(x) for all x in an Array
-func sin() ArrayFunc {
-	return func(x float32) float32 {
+func sin[T Numeric]() ArrayFunc[T] {
+	return func(x T) T {
 		ans := math.Sin(float64(x))
-		return float32(ans)
+		return T(ans)
 	}
 }
 
 // cos(x) for all x in an Array
-func cos() ArrayFunc {
-	return func(x float32) float32 {
+func cos[T Numeric]() ArrayFunc[T] {
+	return func(x T) T {
 		ans := math.Cos(float64(x))
-		return float32(ans)
+		return T(ans)
 	}
 }
 
 // tan(x) for all x in an Array
-func tan() ArrayFunc {
-	return func(x float32) float32 {
+func tan[T Numeric]() ArrayFunc[T] {
+	return func(x T) T {
 		ans := math.Tan(float64(x))
-		return float32(ans)
+		return T(ans)
 	}
 }
 
 // tanh(x) for all x in an Array
-func tanh() ArrayFunc {
-	return func(x float32) float32 {
+func tanh[T Numeric]() ArrayFunc[T] {
+	return func(x T) T {
 		ans := math.Tanh(float64(x))
-		return float32(ans)
+		return T(ans)
 	}
 }
 
 // sigmoid(x) for all x in an Array
-func sigmoid() ArrayFunc {
-	return func(x float32) float32 {
+func sigmoid[T Numeric]() ArrayFunc[T] {
+	return func(x T) T {
 		xn := float64(x)
 		ans := (1 / (1 + math.Exp(-1*xn)))
-		return float32(ans)
+		return T(ans)
 	}
 }
@@ -0,0 +1,178 @@
+package ndgo
+
+import (
+	"math"
+)
+
+// x + y for all x, y in two Arrays
+func add[T Numeric]() BinaryFunc[T] {
+	return func(x, y T) T {
+		return x + y
+	}
+}
+
+// x - y for all x, y in two Arrays
+func sub[T Numeric]() BinaryFunc[T] {
+	return func(x, y T) T {
+		return x - y
+	}
+}
+
+// x * y for all x, y in two Arrays
+func mul[T Numeric]() BinaryFunc[T] {
+	return func(x, y T) T {
+		return x * y
+	}
+}
+
+// x / y for all x, y in two Arrays, integer division by zero gives 0
+func div[T Numeric]() BinaryFunc[T] {
+	if isFloat[T]() {
+		return func(x, y T) T {
+			return x / y
+		}
+	}
+	return func(x, y T) T {
+		if y == 0 {
+			return 0
+		}
+		return x / y
+	}
+}
+
+// x ** y for all x, y in two Arrays
+func pow[T Numeric]() BinaryFunc[T] {
+	if isFloat[T]() {
+		return func(x, y T) T {
+			ans := math.Pow(float64(x), float64(y))
+			return T(ans)
+		}
+	}
+	return func(x, y T) T {
+		// negative integer powers are only integral for 1 and -1
+		if y < 0 {
+			switch int64(x) {
+			case 1:
+				return 1
+			case -1:
+				if int64(y)%2 == 0 {
+					return 1
+				}
+				return x
+			}
+			return 0
+		}
+
+		// exponentiation by squaring
+		ans := T(1)
+		base := x
+		for e := uint64(y); e > 0; e >>= 1 {
+			if e&1 == 1 {
+				ans *= base
+			}
+			base *= base
+		}
+		return ans
+	}
+}
+
+// x mod y for all x, y in two Arrays, with the sign of y
+func mod[T Numeric]() BinaryFunc[T] {
+	if isFloat[T]() {
+		return func(x, y T) T {
+			fx, fy := float64(x), float64(y)
+			ans := math.Mod(fx, fy)
+			if ans != 0 && (ans < 0) != (fy < 0) {
+				ans += fy
+			}
+			return T(ans)
+		}
+	}
+	if isUnsigned[T]() {
+		return func(x, y T) T {
+			if y == 0 {
+				return 0
+			}
+			return T(uint64(x) % uint64(y))
+		}
+	}
+	return func(x, y T) T {
+		if y == 0 {
+			return 0
+		}
+		xi, yi := int64(x), int64(y)
+		ans := xi % yi
+		if ans != 0 && (ans < 0) != (yi < 0) {
+			ans += yi
+		}
+		return T(ans)
+	}
+}
+
+// floor(x / y) for all x, y in two Arrays, integer division by zero gives 0
+func floorDiv[T Numeric]() BinaryFunc[T] {
+	if isFloat[T]() {
+		return func(x, y T) T {
+			ans := math.Floor(float64(x) / float64(y))
+			return T(ans)
+		}
+	}
+	if isUnsigned[T]() {
+		return div[T]()
+	}
+	return func(x, y T) T {
+		if y == 0 {
+			return 0
+		}
+		xi, yi := int64(x), int64(y)
+		ans := xi / yi
+		if xi%yi != 0 && (xi < 0) != (yi < 0) {
+			ans--
+		}
+		return T(ans)
+	}
+}
+
+// max(x, y) for all x, y in two Arrays, NaNs are propagated
+func maximum[T Numeric]() BinaryFunc[T] {
+	return func(x, y T) T {
+		if x != x || x > y {
+			return x
+		}
+		return y
+	}
+}
+
+// min(x, y) for all x, y in two Arrays, NaNs are propagated
+func minimum[T Numeric]() BinaryFunc[T] {
+	return func(x, y T) T {
+		if x != x || x < y {
+			return x
+		}
+		return y
+	}
+}
+
+// atan2(x, y) for all x, y in two Arrays
+func atan2[T Numeric]() BinaryFunc[T] {
+	return func(x, y T) T {
+		ans := math.Atan2(float64(x), float64(y))
+		return T(ans)
+	}
+}
+
+// hypot(x, y) for all x, y in two Arrays
+func hypot[T Numeric]() BinaryFunc[T] {
+	return func(x, y T) T {
+		ans := math.Hypot(float64(x), float64(y))
+		return T(ans)
+	}
+}
+
+// |x| with the sign of y for all x, y in two Arrays
+func copySign[T Numeric]() BinaryFunc[T] {
+	return func(x, y T) T {
+		ans := math.Copysign(float64(x), float64(y))
+		return T(ans)
+	}
+}
@@ -1,216 +1,202 @@
 package ndgo
 
-import (
-	"fmt"
-	"runtime"
-	"sync"
-)
-
-// Operations that will be performed for some index i
+// Operations that will be performed for a range of indices
 // ----------------------------------------------------------------
 
-var opAdd binOpFunc = func(a, b, res *Array, i int) {
-	value := a.At(i) + b.At(i)
-	res.Set(i, value)
-}
-
-var opMul binOpFunc = func(a, b, res *Array, i int) {
-	value := a.At(i) * b.At(i)
-	res.Set(i, value)
+/*
+binOp lifts an elementwise function to a binOpFunc, which computes the
+elements [start, end) of res from a and b. a and b must have the shape of res,
+which is C-contiguous; iterators are used for a and b as that will
+handle transpose, broadcasted and non-contiguous arrays as well.
+*/
+func binOp[T, R Elem](fun func(T, T) R) binOpFunc[T, R] {
+	return func(a, b *Array[T], res *Array[R], start, end int) {
+		ita, itb := a.Iter(), b.Iter()
+		ita.Seek(start)
+		itb.Seek(start)
+		for i := start; i < end; i++ {
+			ita.Next()
+			itb.Next()
+			res.Data[i] = fun(a.Data[ita.Index()], b.Data[itb.Index()])
+		}
+	}
 }
 
 // Binary operations
 // ------------------------------------------------------------------
 
 // concurrent binary operation for given an operation function
-func pBinOpArrays(a, b *Array, opfunc binOpFunc) *Array {
-	res := NewArrayFromShape(a.Shape)
-
-	n_routines := runtime.GOMAXPROCS(0)
-	var chunk_size int = (res.Totalsize + n_routines - 1) / n_routines
+func pBinOpArrays[T, R Elem](a, b *Array[T], opfunc binOpFunc[T, R]) *Array[R] {
+	res := NewArrayFromShape[R](a.Shape)
+	parallelFor(res.Totalsize, func(s, e int) {
+		opfunc(a, b, res, s, e)
+	})
+	return res
+}
 
-	// add number of routines to a waitgroup
-	var wg sync.WaitGroup
-	wg.Add(n_routines)
+func serialBinOpArrays[T, R Elem](a, b *Array[T], opfunc binOpFunc[T, R]) *Array[R] {
+	res := NewArrayFromShape[R](a.Shape)
+	opfunc(a, b, res, 0, res.Totalsize)
+	return res
+}
 
-	for r := 0; r < n_routines; r++ {
-		start := r * chunk_size
-		end := start + chunk_size
-		if end > a.Totalsize {
-			end = a.Totalsize
+/*
+binaryUfunc applies opfunc to the elements of a and b, if the shapes
+are not equal but broadcastable, then broadcasting will take place.
+Large arrays are processed concurrently. name is used in the returned
+BroadcastError when the shapes are not broadcastable.
+*/
+func binaryUfunc[T, R Elem](a, b *Array[T], opfunc binOpFunc[T, R], name string) (*Array[R], error) {
+	if !CheckShapesEqual(a.Shape, b.Shape) {
+		res_shape, err := broadcastShapes(a.Shape, b.Shape)
+		if err != nil {
+			return nil, &BroadcastError{Op: name, Shapes: [][]int{a.Shape, b.Shape}}
 		}
+		a = broadcastArray(a, res_shape)
+		b = broadcastArray(b, res_shape)
+	}
 
-		// start go routine in lambda function
-		go func(s, e int) {
-			defer wg.Done()
+	if a.Totalsize >= PARALLEL_BOUNDARY {
+		return pBinOpArrays(a, b, opfunc), nil
+	}
+	return serialBinOpArrays(a, b, opfunc), nil
+}
 
-			// use linear indices as that will handle transpose and
-			// non-contiguous arrays as well.
-			for i := s; i < e; i++ {
-				opfunc(a, b, res, i)
-			}
-		}(start, end)
+/*
+applies a BinaryFunc to the elements of two Arrays elementwise
+and returns a new Array, broadcasting a and b when needed
+*/
+func TryApplyBinary[T Numeric](a, b *Array[T], fun BinaryFunc[T]) (*Array[T], error) {
+	if fun == nil {
+		return nil, &ValueError{Op: "ApplyBinary", Msg: "function argument nil/missing"}
 	}
+	return binaryUfunc(a, b, binOp(fun), "ApplyBinary")
+}
 
-	wg.Wait()
-	return res
+// ApplyBinary is like TryApplyBinary but panics on error
+func ApplyBinary[T Numeric](a, b *Array[T], fun BinaryFunc[T]) *Array[T] {
+	return must(TryApplyBinary(a, b, fun))
 }
 
-func serialAddArrays(a, b *Array) *Array {
-	res := NewArrayFromShape(a.Shape)
+// a + b elementwise, with broadcasting
+func TryAdd[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(add[T]()), "Add")
+}
 
-	// use linear indices as that will handle transpose and
-	// non-contiguous arrays as well.
-	for i := 0; i < res.Totalsize; i++ {
-		opAdd(a, b, res, i)
-	}
+// Add is like TryAdd but panics on error
+func Add[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryAdd(a, b))
+}
 
-	return res
+// a - b elementwise, with broadcasting
+func TrySub[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(sub[T]()), "Sub")
+}
+
+// Sub is like TrySub but panics on error
+func Sub[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TrySub(a, b))
+}
+
+// a * b elementwise, with broadcasting
+func TryMul[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(mul[T]()), "Mul")
+}
+
+// Mul is like TryMul but panics on error
+func Mul[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryMul(a, b))
 }
 
 /*
-add the elements of two Arrays elementwise
-if the shapes are not equal but broadcastable,
-then broadcasting will take place.
+a / b elementwise, with broadcasting. For integer arrays the
+quotient is truncated towards zero and division by zero gives 0.
 */
-func Add(a, b *Array) *Array {
-	if CheckShapesEqual(a.Shape, b.Shape) {
-		if a.Totalsize >= PARALLEL_BOUNDARY {
-			return pBinOpArrays(a, b, opAdd)
-		}
-		return serialAddArrays(a, b)
-	}
+func TryDiv[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(div[T]()), "Div")
+}
 
-	res_shape, err := broadcastShapes(a.Shape, b.Shape)
-	if err != nil {
-		panic("AddError: cannot add arrays, shapes are not broadcastable")
-	}
+// Div is like TryDiv but panics on error
+func Div[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryDiv(a, b))
+}
 
-	afinal := broadcastArray(a, res_shape)
-	bfinal := broadcastArray(b, res_shape)
+// a ** b elementwise, with broadcasting
+func TryPow[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(pow[T]()), "Pow")
+}
 
-	if afinal.Totalsize >= PARALLEL_BOUNDARY {
-		return pBinOpArrays(afinal, bfinal, opAdd)
-	}
-	return serialAddArrays(afinal, bfinal)
+// Pow is like TryPow but panics on error
+func Pow[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryPow(a, b))
 }
 
-// a - b
-func Sub(a, b *Array) *Array {
-	return Add(a, Neg(b))
+// remainder of a / b elementwise, the result has the sign of b like in numpy
+func TryMod[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(mod[T]()), "Mod")
 }
 
-// can be parallelized
-func serialMulArrays(a, b *Array) *Array {
-	res := NewArrayFromShape(a.Shape)
+// Mod is like TryMod but panics on error
+func Mod[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryMod(a, b))
+}
 
-	// use linear indices as that will handle transpose and
-	// non-contiguous arrays as well.
-	for i := 0; i < res.Totalsize; i++ {
-		opMul(a, b, res, i)
-	}
+// floor(a / b) elementwise, with broadcasting
+func TryFloorDiv[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(floorDiv[T]()), "FloorDiv")
+}
 
-	return res
+// FloorDiv is like TryFloorDiv but panics on error
+func FloorDiv[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryFloorDiv(a, b))
 }
 
-/*
-multiply the elements of two Arrays elementwise
-if the shapes are not equal but broadcastable,
-then broadcasting will take place.
-*/
-func Mul(a, b *Array) *Array {
-	if CheckShapesEqual(a.Shape, b.Shape) {
-		if a.Totalsize >= PARALLEL_BOUNDARY {
-			return pBinOpArrays(a, b, opMul)
-		}
-		return serialMulArrays(a, b)
-	}
+// elementwise maximum of a and b, NaNs are propagated
+func TryMaximum[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(maximum[T]()), "Maximum")
+}
 
-	res_shape, err := broadcastShapes(a.Shape, b.Shape)
-	if err != nil {
-		panic("MulError: cannot multiply arrays, shapes are not broadcastable")
-	}
+// Maximum is like TryMaximum but panics on error
+func Maximum[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryMaximum(a, b))
+}
 
-	afinal := broadcastArray(a, res_shape)
-	bfinal := broadcastArray(b, res_shape)
+// elementwise minimum of a and b, NaNs are propagated
+func TryMinimum[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(minimum[T]()), "Minimum")
+}
 
-	if afinal.Totalsize >= PARALLEL_BOUNDARY {
-		return pBinOpArrays(afinal, bfinal, opMul)
-	}
-	return serialMulArrays(afinal, bfinal)
+// Minimum is like TryMinimum but panics on error
+func Minimum[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryMinimum(a, b))
 }
 
-/*
-matrix multiplication of n-dimensional arrays.
+// arc tangent of a/b elementwise, using the signs of both to pick the quadrant
+func TryAtan2[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(atan2[T]()), "Atan2")
+}
 
-for 2D arrays (m, n) @ (n, d) = (m, d)
-for nD arrays (..., m, n) @ (..., n, d) = (..., m, d)
+// Atan2 is like TryAtan2 but panics on error
+func Atan2[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryAtan2(a, b))
+}
 
-when the dimensions of arrays are greater than 2, we do N matmuls
-on the last two axes of the operands. These N matmuls will be stacked
-in the shape of the higher dimensions.
-*/
-func Matmul(a, b *Array) *Array {
-	if a.Ndim < 2 || b.Ndim < 2 {
-		panic(">> MatmulError: both arrays must have at least 2 dimensions for matmul.")
-	}
-	if a.Shape[a.Ndim-1] != b.Shape[b.Ndim-2] {
-		panic(">> MatmulError: last dimension of first array must match second-last dimension of second array.")
-	}
+// sqrt(a*a + b*b) elementwise, avoiding overflow and underflow
+func TryHypot[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(hypot[T]()), "Hypot")
+}
 
-	// broadcast result shape untill last two axes
-	a_shape_head := a.Shape[:a.Ndim-2]
-	b_shape_head := b.Shape[:b.Ndim-2]
+// Hypot is like TryHypot but panics on error
+func Hypot[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryHypot(a, b))
+}
 
-	res_shape_head, err := broadcastShapes(a_shape_head, b_shape_head)
-	if err != nil {
-		panic(fmt.Sprintf(">> MatmulError: %v", err))
-	}
+// magnitude of a with the sign of b elementwise
+func TryCopySign[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	return binaryUfunc(a, b, binOp(copySign[T]()), "CopySign")
+}
 
-	result_shape := append(res_shape_head, a.Shape[a.Ndim-2], b.Shape[b.Ndim-1])
-
-	result := NewArrayFromShape(result_shape)
-
-	m := a.Shape[a.Ndim-2]
-	n := a.Shape[a.Ndim-1]
-	p := b.Shape[b.Ndim-1]
-
-	totalops := result.Totalsize / (m * p)
-	idxs := arrayIndicesFromShape(result_shape[:len(result_shape)-2])
-
-	for idx := 0; idx < totalops; idx++ {
-		nd_index := idxs.Indices[idx]
-		// perform matmul for this slice
-		// note: can be parallelized
-		for i := 0; i < m; i++ {
-			for j := 0; j < p; j++ {
-				sum := float32(0.)
-				for k := 0; k < n; k++ {
-					// linear 1D index for a and b
-					a_index1d, b_index1d := 0, 0
-					// higher dimensions
-					for d := 0; d < a.Ndim-2; d++ {
-						a_index1d += (nd_index[d] * a.Strides[d])
-					}
-					for d := 0; d < b.Ndim-2; d++ {
-						b_index1d += (nd_index[d] * b.Strides[d])
-					}
-					// last 2 dimensions
-					a_index1d += (i*a.Strides[a.Ndim-2] + k*a.Strides[a.Ndim-1])
-					b_index1d += (k*b.Strides[b.Ndim-2] + j*b.Strides[b.Ndim-1])
-
-					sum += a.Data[a_index1d/a.Itemsize] * b.Data[b_index1d/b.Itemsize]
-				}
-				// same as a and b, for result
-				r_index1d := 0
-				for d := 0; d < a.Ndim-2; d++ {
-					r_index1d += (nd_index[d] * result.Strides[d])
-				}
-				r_index1d += (i*result.Strides[result.Ndim-2] + j*result.Strides[result.Ndim-1])
-
-				result.Data[r_index1d/result.Itemsize] = sum
-			}
-		}
-	}
-	return result
+// CopySign is like TryCopySign but panics on error
+func CopySign[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryCopySign(a, b))
 }
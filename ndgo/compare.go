@@ -0,0 +1,175 @@
+package ndgo
+
+import (
+	"math"
+)
+
+// Comparison operations
+// ----------------------------------------------------------------
+
+// a == b elementwise, with broadcasting
+func TryEqual[T Numeric](a, b *Array[T]) (*Array[bool], error) {
+	return binaryUfunc(a, b, binOp(func(x, y T) bool { return x == y }), "Equal")
+}
+
+// Equal is like TryEqual but panics on error
+func Equal[T Numeric](a, b *Array[T]) *Array[bool] {
+	return must(TryEqual(a, b))
+}
+
+// a != b elementwise, with broadcasting
+func TryNotEqual[T Numeric](a, b *Array[T]) (*Array[bool], error) {
+	return binaryUfunc(a, b, binOp(func(x, y T) bool { return x != y }), "NotEqual")
+}
+
+// NotEqual is like TryNotEqual but panics on error
+func NotEqual[T Numeric](a, b *Array[T]) *Array[bool] {
+	return must(TryNotEqual(a, b))
+}
+
+// a < b elementwise, with broadcasting
+func TryLess[T Numeric](a, b *Array[T]) (*Array[bool], error) {
+	return binaryUfunc(a, b, binOp(func(x, y T) bool { return x < y }), "Less")
+}
+
+// Less is like TryLess but panics on error
+func Less[T Numeric](a, b *Array[T]) *Array[bool] {
+	return must(TryLess(a, b))
+}
+
+// a <= b elementwise, with broadcasting
+func TryLessEqual[T Numeric](a, b *Array[T]) (*Array[bool], error) {
+	return binaryUfunc(a, b, binOp(func(x, y T) bool { return x <= y }), "LessEqual")
+}
+
+// LessEqual is like TryLessEqual but panics on error
+func LessEqual[T Numeric](a, b *Array[T]) *Array[bool] {
+	return must(TryLessEqual(a, b))
+}
+
+// a > b elementwise, with broadcasting
+func TryGreater[T Numeric](a, b *Array[T]) (*Array[bool], error) {
+	return binaryUfunc(a, b, binOp(func(x, y T) bool { return x > y }), "Greater")
+}
+
+// Greater is like TryGreater but panics on error
+func Greater[T Numeric](a, b *Array[T]) *Array[bool] {
+	return must(TryGreater(a, b))
+}
+
+// a >= b elementwise, with broadcasting
+func TryGreaterEqual[T Numeric](a, b *Array[T]) (*Array[bool], error) {
+	return binaryUfunc(a, b, binOp(func(x, y T) bool { return x >= y }), "GreaterEqual")
+}
+
+// GreaterEqual is like TryGreaterEqual but panics on error
+func GreaterEqual[T Numeric](a, b *Array[T]) *Array[bool] {
+	return must(TryGreaterEqual(a, b))
+}
+
+// Logical operations
+// ----------------------------------------------------------------
+
+// a && b elementwise, with broadcasting
+func TryAnd(a, b *Array[bool]) (*Array[bool], error) {
+	return binaryUfunc(a, b, binOp(func(x, y bool) bool { return x && y }), "And")
+}
+
+// And is like TryAnd but panics on error
+func And(a, b *Array[bool]) *Array[bool] {
+	return must(TryAnd(a, b))
+}
+
+// a || b elementwise, with broadcasting
+func TryOr(a, b *Array[bool]) (*Array[bool], error) {
+	return binaryUfunc(a, b, binOp(func(x, y bool) bool { return x || y }), "Or")
+}
+
+// Or is like TryOr but panics on error
+func Or(a, b *Array[bool]) *Array[bool] {
+	return must(TryOr(a, b))
+}
+
+// a != b elementwise for boolean arrays, with broadcasting
+func TryXor(a, b *Array[bool]) (*Array[bool], error) {
+	return binaryUfunc(a, b, binOp(func(x, y bool) bool { return x != y }), "Xor")
+}
+
+// Xor is like TryXor but panics on error
+func Xor(a, b *Array[bool]) *Array[bool] {
+	return must(TryXor(a, b))
+}
+
+// !x for all x in a boolean Array
+func Not(arr *Array[bool]) *Array[bool] {
+	return mapArray(arr, func(x bool) bool { return !x })
+}
+
+// reports whether x is NaN for all x in the Array
+func IsNaN[T Numeric](arr *Array[T]) *Array[bool] {
+	return mapArray(arr, func(x T) bool { return x != x })
+}
+
+// reports whether x is positive or negative infinity for all x in the Array
+func IsInf[T Numeric](arr *Array[T]) *Array[bool] {
+	return mapArray(arr, func(x T) bool { return math.IsInf(float64(x), 0) })
+}
+
+// reports whether x is neither NaN nor an infinity for all x in the Array
+func IsFinite[T Numeric](arr *Array[T]) *Array[bool] {
+	return mapArray(arr, func(x T) bool {
+		f := float64(x)
+		return !math.IsNaN(f) && !math.IsInf(f, 0)
+	})
+}
+
+// Boolean reductions
+// ----------------------------------------------------------------
+
+// reports whether any element of a lane is true, the start of the
+// lane is unused, it only matches the laneFn of reduce
+func anyLane(data []bool, it *NdIter, _, n int) bool {
+	found := false
+	for i := 0; i < n && !found; i++ {
+		it.Next()
+		found = data[it.Index()]
+	}
+	return found
+}
+
+// reports whether every element of a lane is true, the start of the
+// lane is unused, it only matches the laneFn of reduce
+func allLane(data []bool, it *NdIter, _, n int) bool {
+	ok := true
+	for i := 0; i < n && ok; i++ {
+		it.Next()
+		ok = data[it.Index()]
+	}
+	return ok
+}
+
+// TryAny reports whether any element is true over the given axes,
+// all axes are reduced when axes is nil
+func TryAny(arr *Array[bool], axes []int, keepdims bool) (*Array[bool], error) {
+	return reduce(arr, axes, keepdims, "Any", true, anyLane,
+		func(a, b bool) bool { return a || b },
+		func(acc bool, n int) bool { return acc })
+}
+
+// Any is like TryAny but panics on error
+func Any(arr *Array[bool], axes []int, keepdims bool) *Array[bool] {
+	return must(TryAny(arr, axes, keepdims))
+}
+
+// TryAll reports whether every element is true over the given axes,
+// all axes are reduced when axes is nil
+func TryAll(arr *Array[bool], axes []int, keepdims bool) (*Array[bool], error) {
+	return reduce(arr, axes, keepdims, "All", true, allLane,
+		func(a, b bool) bool { return a && b },
+		func(acc bool, n int) bool { return acc })
+}
+
+// All is like TryAll but panics on error
+func All(arr *Array[bool], axes []int, keepdims bool) *Array[bool] {
+	return must(TryAll(arr, axes, keepdims))
+}
@@ -0,0 +1,213 @@
+package ndgo
+
+import (
+	"fmt"
+	"math"
+)
+
+// Constructors of filled and evenly spaced arrays
+// ----------------------------------------------------------------
+
+// TryZeros creates an array of the given shape filled with zeros, like
+// TryNewArrayFromShape
+func TryZeros[T Elem](shape []int) (*Array[T], error) {
+	return TryNewArrayFromShape[T](shape)
+}
+
+// Zeros is like TryZeros but panics on error
+func Zeros[T Elem](shape []int) *Array[T] {
+	return must(TryZeros[T](shape))
+}
+
+// TryOnes creates an array of the given shape filled with ones
+func TryOnes[T Numeric](shape []int) (*Array[T], error) {
+	return TryFull(shape, T(1))
+}
+
+// Ones is like TryOnes but panics on error
+func Ones[T Numeric](shape []int) *Array[T] {
+	return must(TryOnes[T](shape))
+}
+
+// TryFull creates an array of the given shape filled with value
+func TryFull[T Elem](shape []int, value T) (*Array[T], error) {
+	arr, err := TryNewArrayFromShape[T](shape)
+	if err != nil {
+		return nil, err
+	}
+	for i := range arr.Data {
+		arr.Data[i] = value
+	}
+	return arr, nil
+}
+
+// Full is like TryFull but panics on error
+func Full[T Elem](shape []int, value T) *Array[T] {
+	return must(TryFull(shape, value))
+}
+
+// ZerosLike creates an array of zeros with the shape of arr
+func ZerosLike[T Elem](arr *Array[T]) *Array[T] {
+	return NewArrayFromShape[T](arr.Shape)
+}
+
+// OnesLike creates an array of ones with the shape of arr
+func OnesLike[T Numeric](arr *Array[T]) *Array[T] {
+	return Full(arr.Shape, T(1))
+}
+
+// FullLike creates an array filled with value with the shape of arr
+func FullLike[T Elem](arr *Array[T], value T) *Array[T] {
+	return Full(arr.Shape, value)
+}
+
+/*
+TryEye creates an n x m matrix with ones on its k-th diagonal and zeros
+elsewhere. k = 0 is the main diagonal, k > 0 diagonals are above it and
+k < 0 below.
+*/
+func TryEye[T Numeric](n, m, k int) (*Array[T], error) {
+	arr, err := TryNewArrayFromShape[T]([]int{n, m})
+	if err != nil {
+		return nil, err
+	}
+	for i := max(0, -k); i < n && i+k < m; i++ {
+		arr.Data[i*m+i+k] = 1
+	}
+	return arr, nil
+}
+
+// Eye is like TryEye but panics on error
+func Eye[T Numeric](n, m, k int) *Array[T] {
+	return must(TryEye[T](n, m, k))
+}
+
+// TryIdentity creates the n x n identity matrix
+func TryIdentity[T Numeric](n int) (*Array[T], error) {
+	return TryEye[T](n, n, 0)
+}
+
+// Identity is like TryIdentity but panics on error
+func Identity[T Numeric](n int) *Array[T] {
+	return must(TryIdentity[T](n))
+}
+
+/*
+linspace returns num evenly spaced float64 values from start to stop,
+which is included when endpoint is set, and the step between them. The
+step is NaN when it is undefined, i.e. when endpoint is set and num = 1.
+*/
+func linspace(start, stop float64, num int, endpoint bool, op string) ([]float64, float64, error) {
+	if num < 0 {
+		return nil, 0, &ValueError{Op: op, Msg: fmt.Sprintf("number of samples %d must be non-negative", num)}
+	}
+	div := num
+	if endpoint {
+		div = num - 1
+	}
+	step := math.NaN()
+	if div > 0 {
+		step = (stop - start) / float64(div)
+	}
+
+	values := make([]float64, num)
+	for i := range values {
+		if div > 0 {
+			values[i] = start + float64(i)*step
+		} else {
+			values[i] = start
+		}
+	}
+	if endpoint && num > 1 {
+		values[num-1] = stop
+	}
+	return values, step, nil
+}
+
+/*
+TryLinspace creates an array of num evenly spaced values from start to
+stop, like numpy.linspace. stop is included when endpoint is set, and
+the step between the values is returned as well. The values are
+computed as floats and converted to T, so integers are truncated.
+*/
+func TryLinspace[T Numeric](start, stop T, num int, endpoint bool) (*Array[T], float64, error) {
+	values, step, err := linspace(float64(start), float64(stop), num, endpoint, "Linspace")
+	if err != nil {
+		return nil, 0, err
+	}
+	arr := NewArrayFromShape[T]([]int{num})
+	for i, v := range values {
+		arr.Data[i] = T(v)
+	}
+	return arr, step, nil
+}
+
+// Linspace is like TryLinspace but panics on error
+func Linspace[T Numeric](start, stop T, num int, endpoint bool) (*Array[T], float64) {
+	arr, step, err := TryLinspace(start, stop, num, endpoint)
+	if err != nil {
+		panic(err)
+	}
+	return arr, step
+}
+
+/*
+TryLogspace creates an array of num values evenly spaced on a log scale,
+base**start to base**stop, like numpy.logspace. stop is included when
+endpoint is set.
+*/
+func TryLogspace[T Float](start, stop T, num int, endpoint bool, base float64) (*Array[T], error) {
+	values, _, err := linspace(float64(start), float64(stop), num, endpoint, "Logspace")
+	if err != nil {
+		return nil, err
+	}
+	arr := NewArrayFromShape[T]([]int{num})
+	for i, v := range values {
+		arr.Data[i] = T(math.Pow(base, v))
+	}
+	return arr, nil
+}
+
+// Logspace is like TryLogspace but panics on error
+func Logspace[T Float](start, stop T, num int, endpoint bool, base float64) *Array[T] {
+	return must(TryLogspace(start, stop, num, endpoint, base))
+}
+
+/*
+TryGeomspace creates an array of num values from start to stop forming
+a geometric progression, like numpy.geomspace. stop is included when
+endpoint is set. start and stop must be non-zero and of the same sign.
+*/
+func TryGeomspace[T Float](start, stop T, num int, endpoint bool) (*Array[T], error) {
+	if start == 0 || stop == 0 || (start < 0) != (stop < 0) {
+		return nil, &ValueError{Op: "Geomspace", Msg: fmt.Sprintf("start %v and stop %v must be non-zero and of the same sign", start, stop)}
+	}
+	sign := 1.0
+	if start < 0 {
+		sign = -1
+	}
+	// base 10 keeps powers of 10 exact, like numpy
+	logStart, logStop := math.Log10(sign*float64(start)), math.Log10(sign*float64(stop))
+	values, _, err := linspace(logStart, logStop, num, endpoint, "Geomspace")
+	if err != nil {
+		return nil, err
+	}
+
+	arr := NewArrayFromShape[T]([]int{num})
+	for i, v := range values {
+		arr.Data[i] = T(sign * math.Pow(10, v))
+	}
+	// the ends are exact rather than rounded through the logarithms
+	if num > 0 {
+		arr.Data[0] = start
+	}
+	if endpoint && num > 1 {
+		arr.Data[num-1] = stop
+	}
+	return arr, nil
+}
+
+// Geomspace is like TryGeomspace but panics on error
+func Geomspace[T Float](start, stop T, num int, endpoint bool) *Array[T] {
+	return must(TryGeomspace(start, stop, num, endpoint))
+}
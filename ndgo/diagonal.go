@@ -0,0 +1,111 @@
+package ndgo
+
+import (
+	"fmt"
+)
+
+// Diagonals of matrices
+// ----------------------------------------------------------------
+
+/*
+TryDiagonal returns the diagonals of arr over the axes axis1 and axis2
+as a view sharing its data, like numpy.diagonal. These two axes are
+removed and the diagonal is appended as the last axis. offset selects
+a diagonal above (offset > 0) or below (offset < 0) the main one, and
+negative axes count from the end.
+*/
+func (arr *Array[T]) TryDiagonal(offset, axis1, axis2 int) (*Array[T], error) {
+	if arr.Ndim < 2 {
+		return nil, &ShapeError{Op: "Diagonal", Shape: arr.Shape, Msg: "array must have at least 2 dimensions"}
+	}
+	axes, err := normalizeAxes([]int{axis1, axis2}, arr.Ndim, "Diagonal")
+	if err != nil {
+		return nil, err
+	}
+	// normalizeAxes sorts, keep the order given
+	if axis1 < 0 {
+		axis1 += arr.Ndim
+	}
+	if axis2 < 0 {
+		axis2 += arr.Ndim
+	}
+
+	n1, n2 := arr.Shape[axis1], arr.Shape[axis2]
+	start := arr.Offset
+	var length int
+	if offset >= 0 {
+		length = min(n1, n2-offset)
+		start += offset * arr.Strides[axis2]
+	} else {
+		length = min(n1+offset, n2)
+		start -= offset * arr.Strides[axis1]
+	}
+	if length <= 0 {
+		length, start = 0, arr.Offset
+	}
+
+	shape := make([]int, 0, arr.Ndim-1)
+	strides := make([]int, 0, arr.Ndim-1)
+	for ax := 0; ax < arr.Ndim; ax++ {
+		if ax != axes[0] && ax != axes[1] {
+			shape = append(shape, arr.Shape[ax])
+			strides = append(strides, arr.Strides[ax])
+		}
+	}
+	// walking the diagonal advances along both axes
+	shape = append(shape, length)
+	strides = append(strides, arr.Strides[axis1]+arr.Strides[axis2])
+
+	return arr.view(shape, strides, start), nil
+}
+
+// Diagonal is like TryDiagonal but panics on error
+func (arr *Array[T]) Diagonal(offset, axis1, axis2 int) *Array[T] {
+	return must(arr.TryDiagonal(offset, axis1, axis2))
+}
+
+/*
+TryTrace is the sum along the diagonals of arr over the axes axis1 and
+axis2, with the offset of Diagonal. The result has the remaining axes
+of arr, or shape [1] for a matrix.
+*/
+func TryTrace[T Numeric](arr *Array[T], offset, axis1, axis2 int) (*Array[T], error) {
+	diag, err := arr.TryDiagonal(offset, axis1, axis2)
+	if err != nil {
+		return nil, err
+	}
+	return TrySum(diag, []int{diag.Ndim - 1}, false)
+}
+
+// Trace is like TryTrace but panics on error
+func Trace[T Numeric](arr *Array[T], offset, axis1, axis2 int) *Array[T] {
+	return must(TryTrace(arr, offset, axis1, axis2))
+}
+
+/*
+TryDiag builds or extracts a diagonal, like numpy.diag. For a 1D array
+it returns a new square matrix with the elements of arr on the k-th
+diagonal and zeros elsewhere. For a 2D array it returns a copy of its
+k-th diagonal. k > 0 is above the main diagonal and k < 0 below it.
+*/
+func TryDiag[T Elem](arr *Array[T], k int) (*Array[T], error) {
+	switch arr.Ndim {
+	case 1:
+		n := arr.Shape[0] + max(k, -k)
+		res := NewArrayFromShape[T]([]int{n, n})
+		diag := res.Diagonal(k, 0, 1)
+		i := 0
+		for it := arr.Iter(); it.Next(); i++ {
+			diag.Set(i, arr.Data[it.Index()])
+		}
+		return res, nil
+	case 2:
+		return arr.Diagonal(k, 0, 1).Copy(), nil
+	}
+	return nil, &ShapeError{Op: "Diag", Shape: arr.Shape, Msg: fmt.Sprintf("array must have 1 or 2 dimensions, not %d", arr.Ndim)}
+}
+
+// Diag is like TryDiag but panics on error
+func Diag[T Elem](arr *Array[T], k int) *Array[T] {
+	return must(TryDiag(arr, k))
+}
@@ -0,0 +1,66 @@
+package ndgo
+
+import "unsafe"
+
+// Integer is the set of integer element types an Array can hold
+type Integer interface {
+	int | int8 | int16 | int32 | int64 | uint | uint8 | uint16 | uint32 | uint64
+}
+
+// Float is the set of floating point element types an Array can hold
+type Float interface {
+	float32 | float64
+}
+
+// Numeric is the set of element types that support arithmetic
+type Numeric interface {
+	Integer | Float
+}
+
+// Elem is the set of all element types an Array can hold,
+// boolean arrays are used as masks and support no arithmetic
+type Elem interface {
+	Numeric | bool
+}
+
+// size in bytes of a single element of type T
+func sizeof[T Elem]() int {
+	var zero T
+	return int(unsafe.Sizeof(zero))
+}
+
+// reports whether T is an integer type
+func isInteger[T Elem]() bool {
+	var zero T
+	switch any(zero).(type) {
+	case bool, float32, float64:
+		return false
+	}
+	return true
+}
+
+// reports whether T is a floating point type
+func isFloat[T Elem]() bool {
+	var zero T
+	switch any(zero).(type) {
+	case float32, float64:
+		return true
+	}
+	return false
+}
+
+// AsType converts every element of arr to the type U and returns
+// the result as a new Array, e.g. AsType[float64](arr)
+func AsType[U, T Numeric](arr *Array[T]) *Array[U] {
+	return mapArray(arr, func(x T) U { return U(x) })
+}
+
+// reports whether T is an unsigned integer type
+func isUnsigned[T Numeric]() bool {
+	var zero T
+	switch any(zero).(type) {
+	case uint, uint8, uint16, uint32, uint64:
+		return true
+	}
+	return false
+}
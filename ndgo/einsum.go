@@ -0,0 +1,434 @@
+package ndgo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// labels of the broadcast dimensions covered by an ellipsis start here,
+// in the unicode private use area so they never clash with letters
+const ellipsisLabel rune = 0xE000
+
+// an operand of Einsum along with the label of each of its axes
+type einsumOperand[T Numeric] struct {
+	arr    *Array[T]
+	labels []rune
+	fresh  bool // arr does not share data with the inputs
+}
+
+func isEinsumLetter(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+/*
+parseEinsumTerm splits a term like "ij..k" into the letters before and
+after its ellipsis, and reports whether it has an ellipsis at all.
+*/
+func parseEinsumTerm(term string) (before, after []rune, ellipsis bool, err error) {
+	parts := strings.Split(term, "...")
+	if len(parts) > 2 {
+		return nil, nil, false, &ValueError{Op: "Einsum", Msg: fmt.Sprintf("term %q has more than one ellipsis", term)}
+	}
+	for i, part := range parts {
+		for _, c := range part {
+			if !isEinsumLetter(c) {
+				return nil, nil, false, &ValueError{Op: "Einsum", Msg: fmt.Sprintf("invalid character %q in term %q", c, term)}
+			}
+		}
+		if i == 0 {
+			before = []rune(part)
+		} else {
+			after = []rune(part)
+		}
+	}
+	return before, after, len(parts) == 2, nil
+}
+
+/*
+parseEinsum turns subscripts into the labels of every input axis and of
+the output axes. Dimensions covered by an ellipsis are right aligned
+across operands and get labels starting at ellipsisLabel.
+*/
+func parseEinsum(subscripts string, ndims []int) ([][]rune, []rune, error) {
+	subscripts = strings.ReplaceAll(subscripts, " ", "")
+	lhs, rhs, explicit := strings.Cut(subscripts, "->")
+
+	terms := strings.Split(lhs, ",")
+	if len(terms) != len(ndims) {
+		return nil, nil, &ValueError{Op: "Einsum", Msg: fmt.Sprintf("%d terms given for %d operands", len(terms), len(ndims))}
+	}
+
+	inputs := make([][]rune, len(terms))
+	n_ellipsis := 0
+	counts := map[rune]int{}
+	for i, term := range terms {
+		before, after, ellipsis, err := parseEinsumTerm(term)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		e := ndims[i] - len(before) - len(after)
+		if e < 0 || (!ellipsis && e != 0) {
+			return nil, nil, &ValueError{Op: "Einsum", Msg: fmt.Sprintf("term %q does not match operand %d of dimension %d", term, i, ndims[i])}
+		}
+		n_ellipsis = max(n_ellipsis, e)
+
+		inputs[i] = append(inputs[i], before...)
+		for k := 0; k < e; k++ {
+			// placeholder, fixed up once the widest ellipsis is known
+			inputs[i] = append(inputs[i], ellipsisLabel-rune(e-k))
+		}
+		inputs[i] = append(inputs[i], after...)
+
+		for _, c := range append(before, after...) {
+			counts[c]++
+		}
+	}
+
+	// right align the ellipsis dimensions of all operands
+	for _, labels := range inputs {
+		for k, c := range labels {
+			if c < ellipsisLabel && !isEinsumLetter(c) {
+				labels[k] = ellipsisLabel + rune(n_ellipsis) - (ellipsisLabel - c)
+			}
+		}
+	}
+
+	var output []rune
+	if explicit {
+		before, after, ellipsis, err := parseEinsumTerm(rhs)
+		if err != nil {
+			return nil, nil, err
+		}
+		output = append(output, before...)
+		if ellipsis {
+			for k := 0; k < n_ellipsis; k++ {
+				output = append(output, ellipsisLabel+rune(k))
+			}
+		}
+		output = append(output, after...)
+
+		seen := map[rune]bool{}
+		for _, c := range append(before, after...) {
+			if seen[c] {
+				return nil, nil, &ValueError{Op: "Einsum", Msg: fmt.Sprintf("output label %q is repeated", c)}
+			}
+			if counts[c] == 0 {
+				return nil, nil, &ValueError{Op: "Einsum", Msg: fmt.Sprintf("output label %q does not appear in the inputs", c)}
+			}
+			seen[c] = true
+		}
+	} else {
+		// implicit output: broadcast dimensions, then the labels
+		// appearing exactly once in alphabetical order
+		for k := 0; k < n_ellipsis; k++ {
+			output = append(output, ellipsisLabel+rune(k))
+		}
+		var once []rune
+		for c, n := range counts {
+			if n == 1 {
+				once = append(once, c)
+			}
+		}
+		sort.Slice(once, func(i, j int) bool { return once[i] < once[j] })
+		output = append(output, once...)
+	}
+
+	return inputs, output, nil
+}
+
+// size of every label, dimensions of size 1 are broadcast
+func einsumLabelSizes[T Numeric](operands []*Array[T], inputs [][]rune) (map[rune]int, error) {
+	sizes := map[rune]int{}
+	for i, labels := range inputs {
+		for ax, c := range labels {
+			n := operands[i].Shape[ax]
+			old, ok := sizes[c]
+			switch {
+			case !ok || old == 1:
+				sizes[c] = n
+			case n != 1 && n != old:
+				return nil, &ShapeError{Op: "Einsum", Shape: operands[i].Shape, Msg: fmt.Sprintf("size %d of axis %d does not match size %d of its label", n, ax, old)}
+			}
+		}
+	}
+	return sizes, nil
+}
+
+/*
+prepareEinsumOperand takes the diagonal over repeated labels of an
+operand and broadcasts its dimensions of size 1, both as strided views.
+*/
+func prepareEinsumOperand[T Numeric](arr *Array[T], labels []rune, sizes map[rune]int) (einsumOperand[T], error) {
+	var unique []rune
+	var shape, strides []int
+	position := map[rune]int{}
+
+	for ax, c := range labels {
+		if p, ok := position[c]; ok {
+			if arr.Shape[ax] != shape[p] {
+				return einsumOperand[T]{}, &ShapeError{Op: "Einsum", Shape: arr.Shape, Msg: fmt.Sprintf("repeated label %q has different sizes", c)}
+			}
+			// walking the diagonal advances along both axes
+			strides[p] += arr.Strides[ax]
+			continue
+		}
+		position[c] = len(unique)
+		unique = append(unique, c)
+		shape = append(shape, arr.Shape[ax])
+		strides = append(strides, arr.Strides[ax])
+	}
+
+	for i, c := range unique {
+		if shape[i] == 1 && sizes[c] != 1 {
+			shape[i] = sizes[c]
+			strides[i] = 0
+		}
+	}
+
+	return einsumOperand[T]{arr: arr.view(shape, strides, arr.Offset), labels: unique}, nil
+}
+
+// axes of op whose label is not in keep
+func einsumDropAxes[T Numeric](op einsumOperand[T], keep map[rune]bool) []int {
+	var axes []int
+	for ax, c := range op.labels {
+		if !keep[c] {
+			axes = append(axes, ax)
+		}
+	}
+	return axes
+}
+
+// sums op over the axes whose label is not in keep
+func einsumSumOut[T Numeric](op einsumOperand[T], keep map[rune]bool) einsumOperand[T] {
+	axes := einsumDropAxes(op, keep)
+	if len(axes) == 0 {
+		return op
+	}
+
+	var labels []rune
+	for _, c := range op.labels {
+		if keep[c] {
+			labels = append(labels, c)
+		}
+	}
+	return einsumOperand[T]{arr: Sum(op.arr, axes, false), labels: labels, fresh: true}
+}
+
+// op transposed so that its axes follow the given label order,
+// operands without labels are arrays of shape [1]
+func einsumArrange[T Numeric](op einsumOperand[T], order []rune) *Array[T] {
+	if len(order) == 0 {
+		return op.arr
+	}
+	perm := make([]int, len(order))
+	for i, c := range order {
+		for ax, l := range op.labels {
+			if l == c {
+				perm[i] = ax
+			}
+		}
+	}
+	return op.arr.Transpose(perm)
+}
+
+// shape of the given labels, [1] when there are none
+func einsumShape(labels []rune, sizes map[rune]int) []int {
+	shape := make([]int, len(labels))
+	for i, c := range labels {
+		shape[i] = sizes[c]
+	}
+	if len(shape) == 0 {
+		shape = append(shape, 1)
+	}
+	return shape
+}
+
+/*
+einsumContract contracts two operands, keeping the labels in keep. The
+shared labels that are kept become batch axes, the other shared labels
+are summed over, so the contraction is lowered onto a batched Matmul of
+shape (batch, m, k) @ (batch, k, n).
+*/
+func einsumContract[T Numeric](a, b einsumOperand[T], keep map[rune]bool, sizes map[rune]int) einsumOperand[T] {
+	inB := map[rune]bool{}
+	for _, c := range b.labels {
+		inB[c] = true
+	}
+	inA := map[rune]bool{}
+	for _, c := range a.labels {
+		inA[c] = true
+	}
+
+	var batch, contracted, aOnly, bOnly []rune
+	for _, c := range a.labels {
+		switch {
+		case inB[c] && keep[c]:
+			batch = append(batch, c)
+		case inB[c]:
+			contracted = append(contracted, c)
+		default:
+			aOnly = append(aOnly, c)
+		}
+	}
+	for _, c := range b.labels {
+		if !inA[c] {
+			bOnly = append(bOnly, c)
+		}
+	}
+
+	size := func(labels []rune) int {
+		n := 1
+		for _, c := range labels {
+			n *= sizes[c]
+		}
+		return n
+	}
+	nb, m, k, n := size(batch), size(aOnly), size(contracted), size(bOnly)
+
+	aOrder := append(append(append([]rune{}, batch...), aOnly...), contracted...)
+	bOrder := append(append(append([]rune{}, batch...), contracted...), bOnly...)
+	a3 := einsumArrange(a, aOrder).Reshape([]int{nb, m, k})
+	b3 := einsumArrange(b, bOrder).Reshape([]int{nb, k, n})
+
+	labels := append(append(append([]rune{}, batch...), aOnly...), bOnly...)
+	res := Matmul(a3, b3).Reshape(einsumShape(labels, sizes))
+	return einsumOperand[T]{arr: res, labels: labels, fresh: true}
+}
+
+// labels needed after contracting operands i and j, those in the
+// output or in any other remaining operand
+func einsumKeep[T Numeric](ops []einsumOperand[T], i, j int, output []rune) map[rune]bool {
+	keep := map[rune]bool{}
+	for _, c := range output {
+		keep[c] = true
+	}
+	for k, op := range ops {
+		if k == i || k == j {
+			continue
+		}
+		for _, c := range op.labels {
+			keep[c] = true
+		}
+	}
+	return keep
+}
+
+/*
+einsumGreedyPair picks the next pair of operands to contract: the one
+whose result is smallest compared with the operands it replaces, and
+among those, the one needing the fewest multiplications.
+*/
+func einsumGreedyPair[T Numeric](ops []einsumOperand[T], output []rune, sizes map[rune]int) (int, int) {
+	bestI, bestJ := 0, 1
+	bestCost, bestFlops := 0, 0
+	first := true
+
+	for i := 0; i < len(ops); i++ {
+		for j := i + 1; j < len(ops); j++ {
+			keep := einsumKeep(ops, i, j, output)
+
+			union := map[rune]bool{}
+			for _, c := range append(append([]rune{}, ops[i].labels...), ops[j].labels...) {
+				union[c] = true
+			}
+			result, flops := 1, 1
+			for c := range union {
+				flops *= sizes[c]
+				if keep[c] {
+					result *= sizes[c]
+				}
+			}
+
+			cost := result - ops[i].arr.Totalsize - ops[j].arr.Totalsize
+			if first || cost < bestCost || (cost == bestCost && flops < bestFlops) {
+				bestI, bestJ, bestCost, bestFlops = i, j, cost, flops
+				first = false
+			}
+		}
+	}
+	return bestI, bestJ
+}
+
+/*
+TryEinsum evaluates the Einstein summation convention on the operands,
+like numpy.einsum.
+
+	Einsum("ij,jk->ik", a, b)     // matrix product
+	Einsum("ii", a)               // trace
+	Einsum("ii->i", a)            // diagonal
+	Einsum("...ij,...jk", a, b)   // batched matrix product with broadcasting
+	Einsum("bij,bjk,bkl->bil", a, b, c)
+
+Without "->" the output holds the broadcast dimensions followed by the
+labels appearing exactly once, in alphabetical order. Repeated labels
+within a term take a diagonal, and labels missing from the output are
+summed over.
+
+With several operands they are contracted two at a time, in an order
+chosen greedily to keep intermediate results small, and every pairwise
+contraction is lowered onto a batched Matmul. The result never shares
+data with the operands; a result without axes has shape [1].
+*/
+func TryEinsum[T Numeric](subscripts string, operands ...*Array[T]) (*Array[T], error) {
+	if len(operands) == 0 {
+		return nil, &ValueError{Op: "Einsum", Msg: "at least one operand is required"}
+	}
+
+	ndims := make([]int, len(operands))
+	for i, op := range operands {
+		ndims[i] = op.Ndim
+	}
+	inputs, output, err := parseEinsum(subscripts, ndims)
+	if err != nil {
+		return nil, err
+	}
+	sizes, err := einsumLabelSizes(operands, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]einsumOperand[T], len(operands))
+	for i, arr := range operands {
+		if ops[i], err = prepareEinsumOperand(arr, inputs[i], sizes); err != nil {
+			return nil, err
+		}
+	}
+
+	// sum out the labels only a single operand uses
+	for i := range ops {
+		ops[i] = einsumSumOut(ops[i], einsumKeep(ops, i, i, output))
+	}
+
+	for len(ops) > 1 {
+		i, j := einsumGreedyPair(ops, output, sizes)
+		keep := einsumKeep(ops, i, j, output)
+		res := einsumContract(ops[i], ops[j], keep, sizes)
+
+		rest := []einsumOperand[T]{res}
+		for k, op := range ops {
+			if k != i && k != j {
+				rest = append(rest, op)
+			}
+		}
+		ops = rest
+	}
+
+	keep := map[rune]bool{}
+	for _, c := range output {
+		keep[c] = true
+	}
+	last := einsumSumOut(ops[0], keep)
+	res := einsumArrange(last, output)
+	if !last.fresh || !res.C_ORDER {
+		res = res.Copy()
+	}
+	return res.Reshape(einsumShape(output, sizes)), nil
+}
+
+// Einsum is like TryEinsum but panics on error
+func Einsum[T Numeric](subscripts string, operands ...*Array[T]) *Array[T] {
+	return must(TryEinsum(subscripts, operands...))
+}
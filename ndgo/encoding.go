@@ -0,0 +1,340 @@
+package ndgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Serialising arrays with encoding/json, encoding/gob and encoding
+// ----------------------------------------------------------------
+
+/*
+MarshalJSON implements json.Marshaler, an array is encoded as nested
+lists in row major order, e.g. [[1,2,3],[4,5,6]] for shape [2, 3].
+Views are encoded in their logical order. NaN and infinities have no
+JSON representation and give a ValueError.
+*/
+func (arr *Array[T]) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 0, 2*arr.Totalsize+2*arr.Ndim)
+	var err error
+	var write func(d, offset int)
+	write = func(d, offset int) {
+		if d == arr.Ndim {
+			buf, err = appendJSON(buf, arr.Data[offset/arr.Itemsize])
+			return
+		}
+		buf = append(buf, '[')
+		for i := 0; i < arr.Shape[d] && err == nil; i++ {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			write(d+1, offset+i*arr.Strides[d])
+		}
+		buf = append(buf, ']')
+	}
+	write(0, arr.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// appends the JSON encoding of v to buf
+func appendJSON[T Elem](buf []byte, v T) ([]byte, error) {
+	switch x := any(v).(type) {
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, &ValueError{Op: "MarshalJSON", Msg: fmt.Sprintf("%v cannot be encoded in JSON", x)}
+		}
+		return strconv.AppendFloat(buf, x, 'g', -1, 64), nil
+	case float32:
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return nil, &ValueError{Op: "MarshalJSON", Msg: fmt.Sprintf("%v cannot be encoded in JSON", x)}
+		}
+		return strconv.AppendFloat(buf, float64(x), 'g', -1, 32), nil
+	}
+	return fmt.Append(buf, v), nil
+}
+
+// the compact JSON form of an array
+type jsonCompact[T Elem] struct {
+	Shape []int `json:"shape"`
+	Data  []T   `json:"data"`
+}
+
+/*
+decodes nested JSON lists, appending the elements to data in row major
+order, and returns the shape of the lists
+*/
+func decodeNested[T Elem](raw json.RawMessage, data *[]T) ([]int, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 || bytes.TrimSpace(items[0])[0] != '[' {
+		// the innermost lists are decoded at once
+		var values []T
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, err
+		}
+		*data = append(*data, values...)
+		return []int{len(values)}, nil
+	}
+
+	var inner []int
+	for i, item := range items {
+		shape, err := decodeNested(item, data)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			inner = shape
+		} else if !CheckShapesEqual(shape, inner) {
+			return nil, &ShapeError{Op: "UnmarshalJSON", Shape: shape, Msg: fmt.Sprintf("lists are not rectangular, expected shape %v", inner)}
+		}
+	}
+	return append([]int{len(items)}, inner...), nil
+}
+
+/*
+UnmarshalJSON implements json.Unmarshaler, it decodes both the nested
+lists written by MarshalJSON and the compact form written by
+CompactJSON, replacing the contents of arr.
+*/
+func (arr *Array[T]) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return &ValueError{Op: "UnmarshalJSON", Msg: "empty input"}
+	}
+
+	var shape []int
+	var data []T
+	switch b[0] {
+	case '[':
+		var err error
+		if shape, err = decodeNested(b, &data); err != nil {
+			return err
+		}
+	case '{':
+		var c jsonCompact[T]
+		if err := json.Unmarshal(b, &c); err != nil {
+			return err
+		}
+		shape, data = c.Shape, c.Data
+	default:
+		return &ValueError{Op: "UnmarshalJSON", Msg: "an array must be a list or an object with shape and data"}
+	}
+
+	res, err := TryNewArrayFromShape[T](shape)
+	if err != nil {
+		return err
+	}
+	if len(data) != res.Totalsize {
+		return &ShapeError{Op: "UnmarshalJSON", Shape: shape, Msg: fmt.Sprintf("cannot hold %d elements", len(data))}
+	}
+	copy(res.Data, data)
+	*arr = *res
+	return nil
+}
+
+/*
+CompactJSON wraps an Array to encode it in the compact JSON form
+{"shape": [2, 3], "data": [1, 2, 3, 4, 5, 6]} with the data flattened in
+row major order, which is quicker to decode than nested lists, e.g.
+json.Marshal(CompactJSON[float64]{Array: arr}).
+*/
+type CompactJSON[T Elem] struct {
+	Array *Array[T]
+}
+
+// MarshalJSON implements json.Marshaler with the compact form
+func (c CompactJSON[T]) MarshalJSON() ([]byte, error) {
+	arr := c.Array
+	if !arr.C_ORDER {
+		arr = arr.Copy()
+	}
+	start := arr.Offset / arr.Itemsize
+	shape, _ := json.Marshal(arr.Shape)
+	buf := append([]byte(`{"shape":`), shape...)
+	buf = append(buf, `,"data":[`...)
+	for i, v := range arr.Data[start : start+arr.Totalsize] {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		var err error
+		if buf, err = appendJSON(buf, v); err != nil {
+			return nil, err
+		}
+	}
+	return append(buf, "]}"...), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler like Array.UnmarshalJSON
+func (c *CompactJSON[T]) UnmarshalJSON(b []byte) error {
+	if c.Array == nil {
+		c.Array = &Array[T]{}
+	}
+	return c.Array.UnmarshalJSON(b)
+}
+
+// Binary encoding
+// ----------------------------------------------------------------
+
+/*
+The binary encoding is a header followed by the elements in row major
+order as little endian bytes:
+
+	magic   "NDGO"
+	version 1 byte
+	dtype   kind ('b', 'i', 'u' or 'f') and size in bytes, 1 byte each
+	ndim    uvarint, followed by the dimensions as uvarints
+*/
+const (
+	binaryMagic   = "NDGO"
+	binaryVersion = 1
+)
+
+// reads the little endian bytes of buf into the integers out
+func getInts[T Integer](buf []byte, out []T) {
+	size := len(buf) / max(len(out), 1)
+	le := binary.LittleEndian
+	for i := range out {
+		switch size {
+		case 1:
+			out[i] = T(buf[i])
+		case 2:
+			out[i] = T(le.Uint16(buf[2*i:]))
+		case 4:
+			out[i] = T(le.Uint32(buf[4*i:]))
+		default:
+			out[i] = T(le.Uint64(buf[8*i:]))
+		}
+	}
+}
+
+// reads the little endian bytes of buf into values, the inverse of encodeNpy
+func decodeLittleEndian[T Elem](buf []byte, values []T) {
+	le := binary.LittleEndian
+	switch vs := any(values).(type) {
+	case []bool:
+		for i := range vs {
+			vs[i] = buf[i] != 0
+		}
+	case []float32:
+		for i := range vs {
+			vs[i] = math.Float32frombits(le.Uint32(buf[4*i:]))
+		}
+	case []float64:
+		for i := range vs {
+			vs[i] = math.Float64frombits(le.Uint64(buf[8*i:]))
+		}
+	case []int:
+		getInts(buf, vs)
+	case []int8:
+		getInts(buf, vs)
+	case []int16:
+		getInts(buf, vs)
+	case []int32:
+		getInts(buf, vs)
+	case []int64:
+		getInts(buf, vs)
+	case []uint:
+		getInts(buf, vs)
+	case []uint8:
+		getInts(buf, vs)
+	case []uint16:
+		getInts(buf, vs)
+	case []uint32:
+		getInts(buf, vs)
+	case []uint64:
+		getInts(buf, vs)
+	}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, views are encoded
+// in their logical order
+func (arr *Array[T]) MarshalBinary() ([]byte, error) {
+	if !arr.C_ORDER {
+		arr = arr.Copy()
+	}
+	// the kind and size of the npy descr, e.g. "<f8"
+	descr := npyDescr[T]()
+	buf := append([]byte(binaryMagic), binaryVersion, descr[1], byte(arr.Itemsize))
+	buf = binary.AppendUvarint(buf, uint64(arr.Ndim))
+	for _, v := range arr.Shape {
+		buf = binary.AppendUvarint(buf, uint64(v))
+	}
+
+	header := len(buf)
+	start := arr.Offset / arr.Itemsize
+	buf = append(buf, make([]byte, arr.Totalsize*arr.Itemsize)...)
+	encodeNpy(buf[header:], arr.Data[start:start+arr.Totalsize])
+	return buf, nil
+}
+
+/*
+UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing the
+contents of arr. The encoded elements must have the type T, a ValueError
+reports another type, a corrupt header or an unsupported version.
+*/
+func (arr *Array[T]) UnmarshalBinary(b []byte) error {
+	corrupt := &ValueError{Op: "UnmarshalBinary", Msg: "invalid or truncated data"}
+	if len(b) < len(binaryMagic)+3 || string(b[:len(binaryMagic)]) != binaryMagic {
+		return corrupt
+	}
+	b = b[len(binaryMagic):]
+	if b[0] != binaryVersion {
+		return &ValueError{Op: "UnmarshalBinary", Msg: fmt.Sprintf("unsupported version %d", b[0])}
+	}
+	descr := npyDescr[T]()
+	if b[1] != descr[1] || int(b[2]) != sizeof[T]() {
+		return &ValueError{Op: "UnmarshalBinary", Msg: fmt.Sprintf("elements of kind %q and size %d cannot be decoded as %T", b[1], b[2], *new(T))}
+	}
+	b = b[3:]
+
+	ndim, n := binary.Uvarint(b)
+	if n <= 0 || ndim > uint64(len(b)) {
+		return corrupt
+	}
+	b = b[n:]
+	shape := make([]int, ndim)
+	size := uint64(1)
+	for i := range shape {
+		v, n := binary.Uvarint(b)
+		if n <= 0 || v > math.MaxInt32 {
+			return corrupt
+		}
+		shape[i], b = int(v), b[n:]
+		// saturates instead of overflowing, the size is checked below
+		if v > 0 && size > uint64(len(b))/v {
+			size = uint64(len(b)) + 1
+		} else {
+			size *= v
+		}
+	}
+	if size*uint64(sizeof[T]()) != uint64(len(b)) {
+		return corrupt
+	}
+
+	res, err := TryNewArrayFromShape[T](shape)
+	if err != nil {
+		return err
+	}
+	decodeLittleEndian(b, res.Data)
+	*arr = *res
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder with the binary encoding
+func (arr *Array[T]) GobEncode() ([]byte, error) {
+	return arr.MarshalBinary()
+}
+
+// GobDecode implements gob.GobDecoder with the binary encoding
+func (arr *Array[T]) GobDecode(b []byte) error {
+	return arr.UnmarshalBinary(b)
+}
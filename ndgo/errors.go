@@ -0,0 +1,64 @@
+package ndgo
+
+import (
+	"fmt"
+)
+
+/*
+Errors returned by the Try* functions. The functions without the Try
+prefix panic with the same errors, so a recovered panic value can be
+inspected with errors.As as well.
+*/
+
+// ShapeError reports a shape that is invalid for an operation,
+// e.g. a Reshape to a different size or mismatched Matmul operands
+type ShapeError struct {
+	Op    string
+	Shape []int
+	Msg   string
+}
+
+func (e *ShapeError) Error() string {
+	return fmt.Sprintf("%sError: %s (shape %v)", e.Op, e.Msg, e.Shape)
+}
+
+// BroadcastError reports shapes that cannot be broadcast together
+type BroadcastError struct {
+	Op     string
+	Shapes [][]int
+}
+
+func (e *BroadcastError) Error() string {
+	return fmt.Sprintf("%sError: shapes %v are not broadcastable", e.Op, e.Shapes)
+}
+
+// AxisError reports an axis that is out of bounds or repeated,
+// or axes that do not form a valid permutation
+type AxisError struct {
+	Op   string
+	Axis int
+	Ndim int
+	Msg  string
+}
+
+func (e *AxisError) Error() string {
+	return fmt.Sprintf("%sError: axis %d %s for array of dimension %d", e.Op, e.Axis, e.Msg, e.Ndim)
+}
+
+// ValueError reports an invalid argument which is not a shape or axis
+type ValueError struct {
+	Op  string
+	Msg string
+}
+
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("%sError: %s", e.Op, e.Msg)
+}
+
+// must panics with err if it is not nil, and returns res otherwise
+func must[R any](res R, err error) R {
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
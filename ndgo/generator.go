@@ -0,0 +1,478 @@
+package ndgo
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+// second half of the PCG state, fixed so that a Generator
+// is determined by its seed alone
+const pcgIncrement uint64 = 0xda3e39cb94b95bdb
+
+/*
+Generator draws random arrays from a seeded PCG source, so that the
+same seed always gives the same arrays. A Generator is not safe for
+concurrent use.
+*/
+type Generator struct {
+	rng *rand.Rand
+}
+
+// NewGenerator returns a Generator seeded with seed
+func NewGenerator(seed uint64) *Generator {
+	return &Generator{rng: rand.New(rand.NewPCG(seed, pcgIncrement))}
+}
+
+// number of elements drawn from each stream when a large array is
+// filled in parallel, fixed so that the result does not depend on
+// the number of goroutines
+const RANDOM_CHUNK int = 1 << 16
+
+// splitMix64 scrambles x, so that consecutive inputs give
+// unrelated seeds for the streams derived from them
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+/*
+generate returns a new array of the given shape with every element
+drawn by draw. Small arrays are drawn from the stream of g. Large
+arrays are filled in parallel, in chunks of RANDOM_CHUNK elements
+which each use their own PCG stream, seeded from a single draw of g
+and the index of the chunk, so the result is reproducible from the
+seed of g.
+*/
+func generate[T Elem](g *Generator, shape []int, draw func(r *rand.Rand) T) (*Array[T], error) {
+	arr, err := TryNewArrayFromShape[T](shape)
+	if err != nil {
+		return nil, err
+	}
+
+	if arr.Totalsize < PARALLEL_BOUNDARY {
+		for i := range arr.Data {
+			arr.Data[i] = draw(g.rng)
+		}
+		return arr, nil
+	}
+
+	seed := g.rng.Uint64()
+	nchunks := (arr.Totalsize + RANDOM_CHUNK - 1) / RANDOM_CHUNK
+	parallelFor(nchunks, func(s, e int) {
+		for c := s; c < e; c++ {
+			r := rand.New(rand.NewPCG(seed, splitMix64(uint64(c))))
+			for i := c * RANDOM_CHUNK; i < min((c+1)*RANDOM_CHUNK, arr.Totalsize); i++ {
+				arr.Data[i] = draw(r)
+			}
+		}
+	})
+	return arr, nil
+}
+
+// generator seeded from the global source, used by the functions
+// which are not given a Generator
+func globalGenerator() *Generator {
+	return NewGenerator(rand.Uint64())
+}
+
+// uniform float in [0, 1), drawn in the precision of T so
+// that rounding cannot give 1
+func unitFloat[T Float](r *rand.Rand) T {
+	if sizeof[T]() == 4 {
+		return T(r.Float32())
+	}
+	return T(r.Float64())
+}
+
+/*
+Split returns n new Generators seeded from draws of g, for goroutines
+that each need their own independent and reproducible stream.
+*/
+func (g *Generator) Split(n int) []*Generator {
+	gens := make([]*Generator, n)
+	for i := range gens {
+		gens[i] = &Generator{rng: rand.New(rand.NewPCG(g.rng.Uint64(), splitMix64(g.rng.Uint64())))}
+	}
+	return gens
+}
+
+// TryUniform draws floats uniformly from [low, high)
+func (g *Generator) TryUniform(low, high float64, shape []int) (*Array[float64], error) {
+	if !(low <= high) {
+		return nil, &ValueError{Op: "Uniform", Msg: fmt.Sprintf("low %v must not be greater than high %v", low, high)}
+	}
+	return generate(g, shape, func(r *rand.Rand) float64 {
+		return low + r.Float64()*(high-low)
+	})
+}
+
+// Uniform is like TryUniform but panics on error
+func (g *Generator) Uniform(low, high float64, shape []int) *Array[float64] {
+	return must(g.TryUniform(low, high, shape))
+}
+
+// TryNormal draws floats from the normal distribution of
+// the given mean and standard deviation
+func (g *Generator) TryNormal(mean, std float64, shape []int) (*Array[float64], error) {
+	if !(std >= 0) {
+		return nil, &ValueError{Op: "Normal", Msg: fmt.Sprintf("std %v must be non-negative", std)}
+	}
+	return generate(g, shape, func(r *rand.Rand) float64 {
+		return mean + std*r.NormFloat64()
+	})
+}
+
+// Normal is like TryNormal but panics on error
+func (g *Generator) Normal(mean, std float64, shape []int) *Array[float64] {
+	return must(g.TryNormal(mean, std, shape))
+}
+
+/*
+TryTruncatedNormal draws floats from the normal distribution of the
+given mean and standard deviation truncated to [low, high], by inverting
+the normal distribution function between its values at low and high.
+*/
+func (g *Generator) TryTruncatedNormal(mean, std, low, high float64, shape []int) (*Array[float64], error) {
+	if !(std > 0) {
+		return nil, &ValueError{Op: "TruncatedNormal", Msg: fmt.Sprintf("std %v must be positive", std)}
+	}
+	if !(low < high) {
+		return nil, &ValueError{Op: "TruncatedNormal", Msg: fmt.Sprintf("low %v must be less than high %v", low, high)}
+	}
+
+	cdf := func(x float64) float64 { return 0.5 * math.Erfc(-(x-mean)/(std*math.Sqrt2)) }
+	plo, phi := cdf(low), cdf(high)
+	if plo == phi {
+		return nil, &ValueError{Op: "TruncatedNormal", Msg: fmt.Sprintf("interval [%v, %v] is too far in the tails", low, high)}
+	}
+	return generate(g, shape, func(r *rand.Rand) float64 {
+		p := plo + r.Float64()*(phi-plo)
+		x := mean - std*math.Sqrt2*math.Erfcinv(2*p)
+		return math.Min(math.Max(x, low), high)
+	})
+}
+
+// TruncatedNormal is like TryTruncatedNormal but panics on error
+func (g *Generator) TruncatedNormal(mean, std, low, high float64, shape []int) *Array[float64] {
+	return must(g.TryTruncatedNormal(mean, std, low, high, shape))
+}
+
+// TryIntegers draws integers uniformly from [low, high)
+func (g *Generator) TryIntegers(low, high int, shape []int) (*Array[int], error) {
+	if low >= high {
+		return nil, &ValueError{Op: "Integers", Msg: fmt.Sprintf("low %d must be less than high %d", low, high)}
+	}
+	return generate(g, shape, func(r *rand.Rand) int {
+		return low + int(r.Uint64N(uint64(high-low)))
+	})
+}
+
+// Integers is like TryIntegers but panics on error
+func (g *Generator) Integers(low, high int, shape []int) *Array[int] {
+	return must(g.TryIntegers(low, high, shape))
+}
+
+// TryBernoulli draws booleans which are true with probability p
+func (g *Generator) TryBernoulli(p float64, shape []int) (*Array[bool], error) {
+	if !(p >= 0 && p <= 1) {
+		return nil, &ValueError{Op: "Bernoulli", Msg: fmt.Sprintf("probability %v must be in [0, 1]", p)}
+	}
+	return generate(g, shape, func(r *rand.Rand) bool {
+		return r.Float64() < p
+	})
+}
+
+// Bernoulli is like TryBernoulli but panics on error
+func (g *Generator) Bernoulli(p float64, shape []int) *Array[bool] {
+	return must(g.TryBernoulli(p, shape))
+}
+
+// TryExponential draws floats from the exponential distribution
+// of the given scale, the inverse of its rate
+func (g *Generator) TryExponential(scale float64, shape []int) (*Array[float64], error) {
+	if !(scale >= 0) {
+		return nil, &ValueError{Op: "Exponential", Msg: fmt.Sprintf("scale %v must be non-negative", scale)}
+	}
+	return generate(g, shape, func(r *rand.Rand) float64 {
+		return scale * r.ExpFloat64()
+	})
+}
+
+// Exponential is like TryExponential but panics on error
+func (g *Generator) Exponential(scale float64, shape []int) *Array[float64] {
+	return must(g.TryExponential(scale, shape))
+}
+
+/*
+gamma draws from the gamma distribution of shape alpha and scale 1
+with the method of Marsaglia and Tsang. Shapes below 1 are drawn with
+shape alpha + 1 and scaled by U^(1/alpha).
+*/
+func gamma(r *rand.Rand, alpha float64) float64 {
+	if alpha < 1 {
+		u := 1 - r.Float64()
+		return gamma(r, alpha+1) * math.Pow(u, 1/alpha)
+	}
+
+	d := alpha - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := r.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := 1 - r.Float64()
+		if u < 1-0.0331*x*x*x*x || math.Log(u) < 0.5*x*x+d*(1-v+math.Log(v)) {
+			return d * v
+		}
+	}
+}
+
+// TryGamma draws floats from the gamma distribution of shape
+// parameter alpha and the given scale
+func (g *Generator) TryGamma(alpha, scale float64, shape []int) (*Array[float64], error) {
+	if !(alpha > 0) || !(scale > 0) {
+		return nil, &ValueError{Op: "Gamma", Msg: fmt.Sprintf("alpha %v and scale %v must be positive", alpha, scale)}
+	}
+	return generate(g, shape, func(r *rand.Rand) float64 {
+		return scale * gamma(r, alpha)
+	})
+}
+
+// Gamma is like TryGamma but panics on error
+func (g *Generator) Gamma(alpha, scale float64, shape []int) *Array[float64] {
+	return must(g.TryGamma(alpha, scale, shape))
+}
+
+// TryBeta draws floats from the beta distribution of parameters a and b,
+// as X / (X + Y) with X and Y gamma distributed of shapes a and b
+func (g *Generator) TryBeta(a, b float64, shape []int) (*Array[float64], error) {
+	if !(a > 0) || !(b > 0) {
+		return nil, &ValueError{Op: "Beta", Msg: fmt.Sprintf("a %v and b %v must be positive", a, b)}
+	}
+	return generate(g, shape, func(r *rand.Rand) float64 {
+		x := gamma(r, a)
+		return x / (x + gamma(r, b))
+	})
+}
+
+// Beta is like TryBeta but panics on error
+func (g *Generator) Beta(a, b float64, shape []int) *Array[float64] {
+	return must(g.TryBeta(a, b, shape))
+}
+
+/*
+poisson draws from the poisson distribution of mean lam, multiplying
+uniforms for small means, and with the transformed rejection method
+PTRS of Hörmann for means of 10 and above.
+*/
+func poisson(r *rand.Rand, lam float64) int {
+	if lam < 10 {
+		limit := math.Exp(-lam)
+		k, p := 0, r.Float64()
+		for p > limit {
+			k++
+			p *= r.Float64()
+		}
+		return k
+	}
+
+	slam, loglam := math.Sqrt(lam), math.Log(lam)
+	b := 0.931 + 2.53*slam
+	a := -0.059 + 0.02483*b
+	invalpha := 1.1239 + 1.1328/(b-3.4)
+	vr := 0.9277 - 3.6224/(b-2)
+	for {
+		u := r.Float64() - 0.5
+		v := r.Float64()
+		us := 0.5 - math.Abs(u)
+		k := math.Floor((2*a/us+b)*u + lam + 0.43)
+		if us >= 0.07 && v <= vr {
+			return int(k)
+		}
+		if k < 0 || (us < 0.013 && v > us) {
+			continue
+		}
+		lg, _ := math.Lgamma(k + 1)
+		if math.Log(v)+math.Log(invalpha)-math.Log(a/(us*us)+b) <= -lam+k*loglam-lg {
+			return int(k)
+		}
+	}
+}
+
+// TryPoisson draws integers from the poisson distribution of mean lam
+func (g *Generator) TryPoisson(lam float64, shape []int) (*Array[int], error) {
+	if !(lam >= 0) || math.IsInf(lam, 1) {
+		return nil, &ValueError{Op: "Poisson", Msg: fmt.Sprintf("mean %v must be finite and non-negative", lam)}
+	}
+	return generate(g, shape, func(r *rand.Rand) int {
+		return poisson(r, lam)
+	})
+}
+
+// Poisson is like TryPoisson but panics on error
+func (g *Generator) Poisson(lam float64, shape []int) *Array[int] {
+	return must(g.TryPoisson(lam, shape))
+}
+
+/*
+TryChoice draws indices from [0, n) into an array of the given shape.
+weights, when not nil, holds a non-negative weight for each index, the
+probabilities being proportional to them; otherwise every index is
+equally likely. Without replacement every index is drawn at most once,
+so the shape must not hold more elements than there are indices with a
+nonzero weight.
+
+Use the indices to pick elements of an array, e.g. with At.
+*/
+func (g *Generator) TryChoice(n int, shape []int, replace bool, weights []float64) (*Array[int], error) {
+	res, err := TryNewArrayFromShape[int](shape)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, &ValueError{Op: "Choice", Msg: fmt.Sprintf("number of choices %d must be positive", n)}
+	}
+
+	available := n
+	var cumulative []float64
+	if weights != nil {
+		if len(weights) != n {
+			return nil, &ValueError{Op: "Choice", Msg: fmt.Sprintf("%d weights given for %d choices", len(weights), n)}
+		}
+		cumulative = make([]float64, n)
+		total := 0.0
+		available = 0
+		for i, w := range weights {
+			if !(w >= 0) || math.IsInf(w, 1) {
+				return nil, &ValueError{Op: "Choice", Msg: fmt.Sprintf("weight %v must be finite and non-negative", w)}
+			}
+			if w > 0 {
+				available++
+			}
+			total += w
+			cumulative[i] = total
+		}
+		if total == 0 {
+			return nil, &ValueError{Op: "Choice", Msg: "weights must not all be zero"}
+		}
+	}
+	if !replace && res.Totalsize > available {
+		return nil, &ValueError{Op: "Choice", Msg: fmt.Sprintf("cannot draw %d distinct values from %d choices", res.Totalsize, available)}
+	}
+
+	switch {
+	case replace && weights == nil:
+		for i := range res.Data {
+			res.Data[i] = int(g.rng.Uint64N(uint64(n)))
+		}
+	case replace:
+		total := cumulative[n-1]
+		for i := range res.Data {
+			u := g.rng.Float64() * total
+			// first index whose cumulative weight exceeds u, which
+			// never lands on an index of zero weight
+			res.Data[i] = sort.Search(n, func(j int) bool { return cumulative[j] > u })
+		}
+	case weights == nil:
+		// the first elements of a partial Fisher-Yates shuffle
+		perm := make([]int, n)
+		for i := range perm {
+			perm[i] = i
+		}
+		for i := range res.Data {
+			j := i + int(g.rng.Uint64N(uint64(n-i)))
+			perm[i], perm[j] = perm[j], perm[i]
+			res.Data[i] = perm[i]
+		}
+	default:
+		// weighted sampling without replacement of Efraimidis and
+		// Spirakis: the indices with the largest keys log(u) / w
+		keys := make([]float64, n)
+		order := make([]int, n)
+		for i, w := range weights {
+			order[i] = i
+			keys[i] = math.Inf(-1)
+			if w > 0 {
+				keys[i] = math.Log(1-g.rng.Float64()) / w
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool { return keys[order[i]] > keys[order[j]] })
+		copy(res.Data, order)
+	}
+	return res, nil
+}
+
+// Choice is like TryChoice but panics on error
+func (g *Generator) Choice(n int, shape []int, replace bool, weights []float64) *Array[int] {
+	return must(g.TryChoice(n, shape, replace, weights))
+}
+
+// TryPermutation returns a random permutation of [0, n) as a 1D array
+func (g *Generator) TryPermutation(n int) (*Array[int], error) {
+	if n < 0 {
+		return nil, &ValueError{Op: "Permutation", Msg: fmt.Sprintf("length %d must not be negative", n)}
+	}
+	res := NewArrayFromShape[int]([]int{n})
+	for i, v := range g.rng.Perm(n) {
+		res.Data[i] = v
+	}
+	return res, nil
+}
+
+// Permutation is like TryPermutation but panics on error
+func (g *Generator) Permutation(n int) *Array[int] {
+	return must(g.TryPermutation(n))
+}
+
+/*
+TryShuffle shuffles arr in-place along axis, moving whole sub-arrays
+along the other axes together, with a Fisher-Yates shuffle drawn from
+g. Views such as transposed arrays write through to the data they
+share. Negative axes count from the end.
+*/
+func TryShuffle[T Elem](g *Generator, arr *Array[T], axis int) error {
+	if axis < -arr.Ndim || axis >= arr.Ndim {
+		return &AxisError{Op: "Shuffle", Axis: axis, Ndim: arr.Ndim, Msg: "is out of bounds"}
+	}
+	if axis < 0 {
+		axis += arr.Ndim
+	}
+
+	// the sub-array at index i along axis
+	sub := func(i int) *Array[T] {
+		ranges := make([]Range, axis+1)
+		for d := range ranges {
+			ranges[d] = FullRange()
+		}
+		ranges[axis] = Span(i, i+1)
+		return arr.Slice(ranges...)
+	}
+
+	for i := arr.Shape[axis] - 1; i > 0; i-- {
+		j := int(g.rng.Uint64N(uint64(i + 1)))
+		if i == j {
+			continue
+		}
+		a, b := sub(i), sub(j)
+		itb := b.Iter()
+		for ita := a.Iter(); ita.Next(); {
+			itb.Next()
+			a.Data[ita.Index()], b.Data[itb.Index()] = b.Data[itb.Index()], a.Data[ita.Index()]
+		}
+	}
+	return nil
+}
+
+// Shuffle is like TryShuffle but panics on error
+func Shuffle[T Elem](g *Generator, arr *Array[T], axis int) {
+	if err := TryShuffle(g, arr, axis); err != nil {
+		panic(err)
+	}
+}
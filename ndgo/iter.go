@@ -0,0 +1,117 @@
+package ndgo
+
+/*
+NdIter walks the elements of an Array in logical (row major) order,
+similar to numpy's nditer. It works directly on the shape and strides
+of the Array, so any strided layout (transposed, sliced or broadcasted)
+can be traversed without materialising index tables.
+
+	for it := arr.Iter(); it.Next(); {
+		v := arr.Data[it.Index()]
+		...
+	}
+*/
+type NdIter struct {
+	shape       []int
+	strides     []int // in elements
+	backstrides []int // in elements
+	coords      []int
+	base        int // Data index of the first element
+	index       int // Data index of the current element
+	pos         int // linear position of the current element
+	size        int
+	contiguous  bool
+	started     bool
+}
+
+// Iter returns an iterator positioned before the first element of arr
+func (arr *Array[T]) Iter() *NdIter {
+	it := &NdIter{
+		shape:       arr.Shape,
+		strides:     make([]int, arr.Ndim),
+		backstrides: make([]int, arr.Ndim),
+		coords:      make([]int, arr.Ndim),
+		base:        arr.Offset / arr.Itemsize,
+		size:        arr.Totalsize,
+		contiguous:  arr.C_ORDER,
+	}
+	for i := 0; i < arr.Ndim; i++ {
+		it.strides[i] = arr.Strides[i] / arr.Itemsize
+		it.backstrides[i] = arr.Backstrides[i] / arr.Itemsize
+	}
+	it.index = it.base
+	return it
+}
+
+// Next advances the iterator and reports whether there is an element
+func (it *NdIter) Next() bool {
+	if !it.started {
+		it.started = true
+		return it.pos < it.size
+	}
+
+	it.pos++
+	if it.pos >= it.size {
+		return false
+	}
+
+	// fast path for contiguous arrays
+	if it.contiguous {
+		it.index++
+		return true
+	}
+
+	for d := len(it.shape) - 1; d >= 0; d-- {
+		if it.coords[d]+1 < it.shape[d] {
+			it.coords[d]++
+			it.index += it.strides[d]
+			return true
+		}
+		// wrap around this axis
+		it.coords[d] = 0
+		it.index += it.backstrides[d]
+	}
+	return true
+}
+
+// Seek positions the iterator so that the following
+// call to Next moves to the element at linear position pos
+func (it *NdIter) Seek(pos int) {
+	it.pos = pos
+	it.started = false
+	unravelIndex(pos, it.shape, it.coords)
+
+	it.index = it.base
+	for d, c := range it.coords {
+		it.index += c * it.strides[d]
+	}
+}
+
+// Index returns the position of the current element in Data
+func (it *NdIter) Index() int {
+	return it.index
+}
+
+// Pos returns the linear (row major) position of the current element
+func (it *NdIter) Pos() int {
+	return it.pos
+}
+
+// Coords returns the n-dimensional index of the current element,
+// the slice is owned by the iterator and must not be modified.
+// Coords are not tracked on the contiguous fast path.
+func (it *NdIter) Coords() []int {
+	return it.coords
+}
+
+// unravelIndex fills coords with the n-dimensional index of
+// the linear (row major) position pos in an array of given shape
+func unravelIndex(pos int, shape, coords []int) {
+	for d := len(shape) - 1; d >= 0; d-- {
+		coords[d] = 0
+		if shape[d] > 0 {
+			coords[d] = pos % shape[d]
+			pos /= shape[d]
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Package linalg implements dense linear algebra on ndgo arrays.
+
+Every function works on the last two axes of its operands and is
+batched over the leading axes, which are broadcast together the same
+way Matmul broadcasts its head shape. The computations run in float64
+whatever the element type of the arrays, and the results are converted
+back to it.
+*/
+package linalg
+
+import (
+	"fmt"
+
+	ng "ndgo/ndgo"
+)
+
+// SingularError reports a matrix which is singular, Index is its
+// position in the flattened batch of matrices
+type SingularError struct {
+	Op    string
+	Index int
+}
+
+func (e *SingularError) Error() string {
+	return fmt.Sprintf("%sError: matrix %d of the batch is singular", e.Op, e.Index)
+}
+
+// must panics with err if it is not nil, and returns res otherwise
+func must[R any](res R, err error) R {
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+/*
+matrices is a batch of m x n matrices copied to float64, stored one
+after another in row major order. head is the shape of the batch.
+*/
+type matrices struct {
+	data []float64
+	head []int
+	m, n int
+}
+
+// number of matrices in the batch, which holds no data when the
+// matrices are empty
+func (b *matrices) count() int {
+	return ng.ShapeSize(b.head)
+}
+
+// matrix i of the batch
+func (b *matrices) at(i int) []float64 {
+	return b.data[i*b.m*b.n : (i+1)*b.m*b.n]
+}
+
+// returns a ShapeError unless arr has at least 2 dimensions,
+// and unless the last two are equal when square is set
+func checkMatrices[T ng.Float](arr *ng.Array[T], square bool, op string) error {
+	if arr.Ndim < 2 {
+		return &ng.ShapeError{Op: op, Shape: arr.Shape, Msg: "array must have at least 2 dimensions"}
+	}
+	if square && arr.Shape[arr.Ndim-1] != arr.Shape[arr.Ndim-2] {
+		return &ng.ShapeError{Op: op, Shape: arr.Shape, Msg: "last 2 dimensions must be square"}
+	}
+	return nil
+}
+
+/*
+toMatrices copies arr to a batch of float64 matrices. When head is not
+nil, arr is first broadcast to that batch shape. arr must have passed
+checkMatrices.
+*/
+func toMatrices[T ng.Float](arr *ng.Array[T], head []int) *matrices {
+	m, n := arr.Shape[arr.Ndim-2], arr.Shape[arr.Ndim-1]
+	if head == nil {
+		head = arr.Shape[:arr.Ndim-2]
+	} else {
+		arr = ng.BroadcastTo(arr, append(append([]int{}, head...), m, n))
+	}
+	return &matrices{
+		data: ng.AsType[float64](arr).Data,
+		head: append([]int{}, head...),
+		m:    m,
+		n:    n,
+	}
+}
+
+// array of element type T with the given shape holding data,
+// a shape without axes gives an array of shape [1]
+func fromFloat64[T ng.Float](data []float64, shape []int) *ng.Array[T] {
+	if len(shape) == 0 {
+		shape = []int{1}
+	}
+	res := ng.NewArrayFromShape[T](shape)
+	for i, v := range data {
+		res.Data[i] = T(v)
+	}
+	return res
+}
+
+// transposeMatrices returns a view of arr with its last two axes swapped,
+// the transposes of its matrices
+func transposeMatrices[T ng.Elem](arr *ng.Array[T]) *ng.Array[T] {
+	perm := make([]int, arr.Ndim)
+	for i := range perm {
+		perm[i] = i
+	}
+	perm[arr.Ndim-2], perm[arr.Ndim-1] = arr.Ndim-1, arr.Ndim-2
+	return arr.Transpose(perm)
+}
+
+// shape of a batch of m x n matrices
+func batchShape(head []int, dims ...int) []int {
+	return append(append([]int{}, head...), dims...)
+}
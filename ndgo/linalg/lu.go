@@ -0,0 +1,305 @@
+package linalg
+
+import (
+	"math"
+
+	ng "ndgo/ndgo"
+)
+
+/*
+luFactor factors the n x n matrix a in-place with partial pivoting, so
+that row perm[i] of the original matrix is row i of L @ U. L has a unit
+diagonal and is stored below the diagonal of a, U on and above it.
+
+It returns the sign of the permutation, and false when a pivot is zero,
+in which case the factorisation is complete but U is singular.
+*/
+func luFactor(a []float64, n int, perm []int) (float64, bool) {
+	for i := range perm {
+		perm[i] = i
+	}
+	sign, ok := 1.0, true
+
+	for k := 0; k < n; k++ {
+		// pick the largest pivot in column k
+		p := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(a[i*n+k]) > math.Abs(a[p*n+k]) {
+				p = i
+			}
+		}
+		if p != k {
+			for j := 0; j < n; j++ {
+				a[k*n+j], a[p*n+j] = a[p*n+j], a[k*n+j]
+			}
+			perm[k], perm[p] = perm[p], perm[k]
+			sign = -sign
+		}
+
+		pivot := a[k*n+k]
+		if pivot == 0 {
+			ok = false
+			continue
+		}
+		for i := k + 1; i < n; i++ {
+			f := a[i*n+k] / pivot
+			a[i*n+k] = f
+			if f == 0 {
+				continue
+			}
+			for j := k + 1; j < n; j++ {
+				a[i*n+j] -= f * a[k*n+j]
+			}
+		}
+	}
+	return sign, ok
+}
+
+/*
+luSolve solves L @ U @ x = b[perm] for the k columns of the n x k
+matrix b, using a factorisation from luFactor, and writes x to x.
+*/
+func luSolve(lu []float64, n int, perm []int, b []float64, k int, x []float64) {
+	for i := 0; i < n; i++ {
+		copy(x[i*k:(i+1)*k], b[perm[i]*k:(perm[i]+1)*k])
+	}
+
+	// forward substitution with the unit lower triangle
+	for i := 0; i < n; i++ {
+		xi := x[i*k : (i+1)*k]
+		for j := 0; j < i; j++ {
+			l := lu[i*n+j]
+			xj := x[j*k : (j+1)*k]
+			for c := range xi {
+				xi[c] -= l * xj[c]
+			}
+		}
+	}
+
+	// back substitution with the upper triangle
+	for i := n - 1; i >= 0; i-- {
+		xi := x[i*k : (i+1)*k]
+		for j := i + 1; j < n; j++ {
+			u := lu[i*n+j]
+			xj := x[j*k : (j+1)*k]
+			for c := range xi {
+				xi[c] -= u * xj[c]
+			}
+		}
+		for c := range xi {
+			xi[c] /= lu[i*n+i]
+		}
+	}
+}
+
+/*
+TryLU is the LU factorisation with partial pivoting of the square
+matrices of a (..., n, n), returning p, l and u of the same shape with
+
+	a = p @ l @ u
+
+where p is a permutation matrix, l is lower triangular with a unit
+diagonal and u is upper triangular. Singular matrices are factored as
+well, with zeros on the diagonal of u.
+*/
+func TryLU[T ng.Float](a *ng.Array[T]) (p, l, u *ng.Array[T], err error) {
+	if err := checkMatrices(a, true, "LU"); err != nil {
+		return nil, nil, nil, err
+	}
+
+	mats := toMatrices(a, nil)
+	n := mats.n
+	pData := make([]float64, len(mats.data))
+	lData := make([]float64, len(mats.data))
+	uData := make([]float64, len(mats.data))
+	perm := make([]int, n)
+
+	for b := 0; b < mats.count(); b++ {
+		lu := mats.at(b)
+		luFactor(lu, n, perm)
+		off := b * n * n
+		for i := 0; i < n; i++ {
+			pData[off+perm[i]*n+i] = 1
+			lData[off+i*n+i] = 1
+			for j := 0; j < n; j++ {
+				if j < i {
+					lData[off+i*n+j] = lu[i*n+j]
+				} else {
+					uData[off+i*n+j] = lu[i*n+j]
+				}
+			}
+		}
+	}
+
+	shape := batchShape(mats.head, n, n)
+	return fromFloat64[T](pData, shape), fromFloat64[T](lData, shape), fromFloat64[T](uData, shape), nil
+}
+
+// LU is like TryLU but panics on error
+func LU[T ng.Float](a *ng.Array[T]) (p, l, u *ng.Array[T]) {
+	p, l, u, err := TryLU(a)
+	if err != nil {
+		panic(err)
+	}
+	return p, l, u
+}
+
+/*
+TrySolve solves a @ x = b for x, where a is (..., n, n) and b is either
+(..., n, k) or a vector (n,). The batch axes of a and b are broadcast
+together. A SingularError is returned when a matrix of a is singular.
+*/
+func TrySolve[T ng.Float](a, b *ng.Array[T]) (*ng.Array[T], error) {
+	if err := checkMatrices(a, true, "Solve"); err != nil {
+		return nil, err
+	}
+	n := a.Shape[a.Ndim-1]
+
+	vector := b.Ndim == 1
+	if vector {
+		b = b.Reshape([]int{b.Shape[0], 1})
+	} else if err := checkMatrices(b, false, "Solve"); err != nil {
+		return nil, err
+	}
+	if b.Shape[b.Ndim-2] != n {
+		return nil, &ng.ShapeError{Op: "Solve", Shape: b.Shape, Msg: "first dimension of the right hand side must match the size of the matrices"}
+	}
+
+	head, err := ng.TryBroadcastShapes(a.Shape[:a.Ndim-2], b.Shape[:b.Ndim-2])
+	if err != nil {
+		return nil, &ng.BroadcastError{Op: "Solve", Shapes: [][]int{a.Shape, b.Shape}}
+	}
+	as, bs := toMatrices(a, head), toMatrices(b, head)
+	k := bs.n
+
+	x := make([]float64, len(bs.data))
+	perm := make([]int, n)
+	for i := 0; i < as.count(); i++ {
+		lu := as.at(i)
+		if _, ok := luFactor(lu, n, perm); !ok {
+			return nil, &SingularError{Op: "Solve", Index: i}
+		}
+		luSolve(lu, n, perm, bs.at(i), k, x[i*n*k:(i+1)*n*k])
+	}
+
+	if vector {
+		return fromFloat64[T](x, batchShape(head, n)), nil
+	}
+	return fromFloat64[T](x, batchShape(head, n, k)), nil
+}
+
+// Solve is like TrySolve but panics on error
+func Solve[T ng.Float](a, b *ng.Array[T]) *ng.Array[T] {
+	return must(TrySolve(a, b))
+}
+
+/*
+TryInv is the inverse of the square matrices of a (..., n, n). A
+SingularError is returned when a matrix is singular.
+*/
+func TryInv[T ng.Float](a *ng.Array[T]) (*ng.Array[T], error) {
+	if err := checkMatrices(a, true, "Inv"); err != nil {
+		return nil, err
+	}
+
+	mats := toMatrices(a, nil)
+	n := mats.n
+	eye := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		eye[i*n+i] = 1
+	}
+
+	inv := make([]float64, len(mats.data))
+	perm := make([]int, n)
+	for i := 0; i < mats.count(); i++ {
+		lu := mats.at(i)
+		if _, ok := luFactor(lu, n, perm); !ok {
+			return nil, &SingularError{Op: "Inv", Index: i}
+		}
+		luSolve(lu, n, perm, eye, n, inv[i*n*n:(i+1)*n*n])
+	}
+	return fromFloat64[T](inv, batchShape(mats.head, n, n)), nil
+}
+
+// Inv is like TryInv but panics on error
+func Inv[T ng.Float](a *ng.Array[T]) *ng.Array[T] {
+	return must(TryInv(a))
+}
+
+/*
+TrySlogDet returns the sign and the natural logarithm of the absolute
+value of the determinant of the square matrices of a (..., n, n), which
+does not overflow for large matrices. Both results have the batch shape
+of a, or shape [1] for a single matrix. Singular matrices have a sign
+of 0 and a logarithm of -Inf, 0x0 matrices a sign of 1 and a logarithm
+of 0.
+*/
+func TrySlogDet[T ng.Float](a *ng.Array[T]) (sign, logabsdet *ng.Array[T], err error) {
+	if err := checkMatrices(a, true, "SlogDet"); err != nil {
+		return nil, nil, err
+	}
+
+	mats := toMatrices(a, nil)
+	n := mats.n
+	signs := make([]float64, mats.count())
+	logs := make([]float64, mats.count())
+	perm := make([]int, n)
+
+	for i := range signs {
+		lu := mats.at(i)
+		s, ok := luFactor(lu, n, perm)
+		if !ok {
+			signs[i], logs[i] = 0, math.Inf(-1)
+			continue
+		}
+		for j := 0; j < n; j++ {
+			d := lu[j*n+j]
+			if d < 0 {
+				s = -s
+			}
+			logs[i] += math.Log(math.Abs(d))
+		}
+		signs[i] = s
+	}
+	return fromFloat64[T](signs, mats.head), fromFloat64[T](logs, mats.head), nil
+}
+
+// SlogDet is like TrySlogDet but panics on error
+func SlogDet[T ng.Float](a *ng.Array[T]) (sign, logabsdet *ng.Array[T]) {
+	sign, logabsdet, err := TrySlogDet(a)
+	if err != nil {
+		panic(err)
+	}
+	return sign, logabsdet
+}
+
+/*
+TryDet is the determinant of the square matrices of a (..., n, n). The
+result has the batch shape of a, or shape [1] for a single matrix. A
+0x0 matrix has a determinant of 1, like in numpy.
+*/
+func TryDet[T ng.Float](a *ng.Array[T]) (*ng.Array[T], error) {
+	if err := checkMatrices(a, true, "Det"); err != nil {
+		return nil, err
+	}
+
+	mats := toMatrices(a, nil)
+	n := mats.n
+	dets := make([]float64, mats.count())
+	perm := make([]int, n)
+
+	for i := range dets {
+		lu := mats.at(i)
+		det, _ := luFactor(lu, n, perm)
+		for j := 0; j < n; j++ {
+			det *= lu[j*n+j]
+		}
+		dets[i] = det
+	}
+	return fromFloat64[T](dets, mats.head), nil
+}
+
+// Det is like TryDet but panics on error
+func Det[T ng.Float](a *ng.Array[T]) *ng.Array[T] {
+	return must(TryDet(a))
+}
@@ -0,0 +1,281 @@
+package linalg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	ng "ndgo/ndgo"
+)
+
+type ordKind int
+
+const (
+	ordDefault ordKind = iota
+	ordP
+	ordFro
+	ordNuclear
+)
+
+/*
+Ord selects the norm computed by Norm. Its zero value is the default
+norm: the 2-norm of vectors and the Frobenius norm of matrices.
+*/
+type Ord struct {
+	kind ordKind
+	p    float64
+}
+
+// P is the order p of a norm, which can be math.Inf(1) or math.Inf(-1)
+func P(p float64) Ord {
+	return Ord{kind: ordP, p: p}
+}
+
+var (
+	// Frobenius norm of matrices
+	Fro = Ord{kind: ordFro}
+	// nuclear norm of matrices, the sum of their singular values
+	Nuclear = Ord{kind: ordNuclear}
+)
+
+func (ord Ord) String() string {
+	switch ord.kind {
+	case ordFro:
+		return "Fro"
+	case ordNuclear:
+		return "Nuclear"
+	case ordP:
+		return fmt.Sprintf("P(%v)", ord.p)
+	}
+	return "default"
+}
+
+// p-norm of the vector x
+func vectorNorm(x []float64, p float64) float64 {
+	res := 0.0
+	switch {
+	case math.IsInf(p, 1):
+		for _, v := range x {
+			res = math.Max(res, math.Abs(v))
+		}
+	case math.IsInf(p, -1):
+		res = math.Inf(1)
+		for _, v := range x {
+			res = math.Min(res, math.Abs(v))
+		}
+	case p == 0:
+		// not a norm, the number of nonzero elements
+		for _, v := range x {
+			if v != 0 {
+				res++
+			}
+		}
+	case p == 1:
+		for _, v := range x {
+			res += math.Abs(v)
+		}
+	case p == 2:
+		for _, v := range x {
+			res += v * v
+		}
+		res = math.Sqrt(res)
+	default:
+		for _, v := range x {
+			res += math.Pow(math.Abs(v), p)
+		}
+		res = math.Pow(res, 1/p)
+	}
+	return res
+}
+
+// norm of the m x n matrix a, the kind of ord is ordP or ordNuclear
+func matrixNorm(a []float64, m, n int, ord Ord) (float64, error) {
+	if ord.kind == ordNuclear || ord.p == 2 || ord.p == -2 {
+		_, s, _ := svd(a, m, n, false)
+		switch {
+		case len(s) == 0:
+			return 0, nil
+		case ord.kind == ordNuclear:
+			return vectorNorm(s, 1), nil
+		case ord.p == 2:
+			return s[0], nil
+		}
+		return s[len(s)-1], nil
+	}
+
+	// max or min absolute column sum (p = 1), or row sum (p = inf)
+	var sums []float64
+	switch {
+	case math.Abs(ord.p) == 1:
+		sums = make([]float64, n)
+		for i := 0; i < m; i++ {
+			for j := 0; j < n; j++ {
+				sums[j] += math.Abs(a[i*n+j])
+			}
+		}
+	case math.IsInf(ord.p, 0):
+		sums = make([]float64, m)
+		for i := 0; i < m; i++ {
+			sums[i] = vectorNorm(a[i*n:(i+1)*n], 1)
+		}
+	default:
+		return 0, &ng.ValueError{Op: "Norm", Msg: fmt.Sprintf("invalid norm order %v for matrices", ord)}
+	}
+	if ord.p > 0 {
+		return vectorNorm(sums, math.Inf(1)), nil
+	}
+	return vectorNorm(sums, math.Inf(-1)), nil
+}
+
+/*
+TryNorm is the norm of a over the given axes, like numpy.linalg.norm.
+
+With one axis it is the vector norm P(p) of order p along that axis,
+with P(math.Inf(1)) the largest absolute value. With two axes it is
+the matrix norm over them, the first being the rows: Fro, Nuclear,
+P(1) and P(-1) for the largest and smallest absolute column sums,
+P(math.Inf(1)) and P(math.Inf(-1)) for the row sums, and P(2) and
+P(-2) for the largest and smallest singular values.
+
+nil axes select every axis for arrays of 1 or 2 dimensions; for more
+dimensions only the default order is allowed and gives the 2-norm of
+the flattened array. If keepdims is true the reduced axes are left in
+the result with size one. The result has shape [1] when no axis is
+left.
+*/
+func TryNorm[T ng.Float](a *ng.Array[T], ord Ord, axes []int, keepdims bool) (*ng.Array[T], error) {
+	if axes == nil {
+		switch {
+		case a.Ndim <= 2:
+			axes = []int{0, 1}[:a.Ndim]
+		case ord.kind != ordDefault:
+			return nil, &ng.ValueError{Op: "Norm", Msg: fmt.Sprintf("norm order %v needs 1 or 2 axes", ord)}
+		default:
+			// 2-norm of the flattened array
+			flat := a.Reshape([]int{a.Totalsize})
+			res, err := TryNorm(flat, ord, nil, false)
+			if err == nil && keepdims {
+				ones := make([]int, a.Ndim)
+				for i := range ones {
+					ones[i] = 1
+				}
+				res = res.Reshape(ones)
+			}
+			return res, err
+		}
+	}
+
+	if len(axes) != 1 && len(axes) != 2 {
+		return nil, &ng.ValueError{Op: "Norm", Msg: fmt.Sprintf("%d axes given, norms are taken over 1 or 2 axes", len(axes))}
+	}
+	// the order of the axes matters, the first one indexes the rows
+	norm_axes, err := ng.CheckAxes(axes, a.Ndim, "Norm")
+	if err != nil {
+		return nil, err
+	}
+	reduced := make([]bool, a.Ndim)
+	for _, ax := range norm_axes {
+		reduced[ax] = true
+	}
+	if len(axes) == 1 && (ord.kind == ordFro || ord.kind == ordNuclear) {
+		return nil, &ng.ValueError{Op: "Norm", Msg: fmt.Sprintf("norm order %v is only defined for matrices", ord)}
+	}
+
+	// move the reduced axes last and copy to float64, so that every
+	// norm is taken over a contiguous lane
+	var perm, shape []int
+	for ax := 0; ax < a.Ndim; ax++ {
+		if !reduced[ax] {
+			perm = append(perm, ax)
+			shape = append(shape, a.Shape[ax])
+		} else if keepdims {
+			shape = append(shape, 1)
+		}
+	}
+	perm = append(perm, norm_axes...)
+	data := ng.AsType[float64](a.Transpose(perm)).Data
+
+	rows := a.Shape[norm_axes[0]]
+	cols := 1
+	if len(norm_axes) == 2 {
+		cols = a.Shape[norm_axes[1]]
+	}
+	lane := rows * cols
+
+	norms := make([]float64, ng.ShapeSize(shape))
+	for i := range norms {
+		x := data[i*lane : (i+1)*lane]
+		var err error
+		switch {
+		case len(norm_axes) == 1 && ord.kind == ordDefault:
+			norms[i] = vectorNorm(x, 2)
+		case len(norm_axes) == 1:
+			norms[i] = vectorNorm(x, ord.p)
+		case ord.kind == ordDefault || ord.kind == ordFro:
+			norms[i] = vectorNorm(x, 2)
+		default:
+			norms[i], err = matrixNorm(x, rows, cols, ord)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fromFloat64[T](norms, shape), nil
+}
+
+// Norm is like TryNorm but panics on error
+func Norm[T ng.Float](a *ng.Array[T], ord Ord, axes []int, keepdims bool) *ng.Array[T] {
+	return must(TryNorm(a, ord, axes, keepdims))
+}
+
+/*
+TryMatrixPower raises the square matrices of a (..., n, n) to the
+integer power p by repeated squaring, with O(log p) calls to Matmul.
+p = 0 gives identity matrices and negative powers are powers of the
+inverse, for which a SingularError is returned when a matrix is
+singular.
+*/
+func TryMatrixPower[T ng.Float](a *ng.Array[T], p int) (*ng.Array[T], error) {
+	if err := checkMatrices(a, true, "MatrixPower"); err != nil {
+		return nil, err
+	}
+	if p < 0 {
+		inv, err := TryInv(a)
+		var singular *SingularError
+		if errors.As(err, &singular) {
+			return nil, &SingularError{Op: "MatrixPower", Index: singular.Index}
+		} else if err != nil {
+			return nil, err
+		}
+		a, p = inv, -p
+	}
+
+	var res *ng.Array[T]
+	base := a
+	for ; p > 0; p >>= 1 {
+		if p&1 == 1 {
+			if res == nil {
+				res = base.Copy()
+			} else {
+				res = ng.Matmul(res, base)
+			}
+		}
+		if p > 1 {
+			base = ng.Matmul(base, base)
+		}
+	}
+
+	if res == nil {
+		res = ng.NewArrayFromShape[T](a.Shape)
+		diag := res.Diagonal(0, -2, -1)
+		for i := 0; i < diag.Totalsize; i++ {
+			diag.Set(i, 1)
+		}
+	}
+	return res, nil
+}
+
+// MatrixPower is like TryMatrixPower but panics on error
+func MatrixPower[T ng.Float](a *ng.Array[T], p int) *ng.Array[T] {
+	return must(TryMatrixPower(a, p))
+}
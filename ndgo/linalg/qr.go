@@ -0,0 +1,335 @@
+package linalg
+
+import (
+	"fmt"
+	"math"
+
+	ng "ndgo/ndgo"
+)
+
+// QRMode selects the shapes returned by QR
+type QRMode int
+
+const (
+	// q is (m, k) and r is (k, n), with k = min(m, n)
+	Reduced QRMode = iota
+	// q is (m, m) and r is (m, n)
+	Complete
+)
+
+// NotPositiveDefiniteError reports a matrix which is not positive
+// definite, Index is its position in the flattened batch of matrices
+type NotPositiveDefiniteError struct {
+	Op    string
+	Index int
+}
+
+func (e *NotPositiveDefiniteError) Error() string {
+	return fmt.Sprintf("%sError: matrix %d of the batch is not positive definite", e.Op, e.Index)
+}
+
+/*
+householderQR reduces the m x n matrix a in-place to the upper
+triangular r of its QR factorisation, and returns the unit vectors of
+the min(m, n) Householder reflections I - 2 v v^T, where v of step j
+applies to rows j and below. A nil vector is the identity.
+
+When perm is not nil the columns are pivoted, bringing the remaining
+column of largest norm forward at every step, and perm[j] is the
+original column of column j of r.
+*/
+func householderQR(a []float64, m, n int, perm []int) [][]float64 {
+	k := min(m, n)
+	vs := make([][]float64, k)
+	for j := range perm {
+		perm[j] = j
+	}
+
+	for j := 0; j < k; j++ {
+		if perm != nil {
+			best, bestNorm := j, -1.0
+			for c := j; c < n; c++ {
+				norm := 0.0
+				for i := j; i < m; i++ {
+					norm += a[i*n+c] * a[i*n+c]
+				}
+				if norm > bestNorm {
+					best, bestNorm = c, norm
+				}
+			}
+			if best != j {
+				for i := 0; i < m; i++ {
+					a[i*n+j], a[i*n+best] = a[i*n+best], a[i*n+j]
+				}
+				perm[j], perm[best] = perm[best], perm[j]
+			}
+		}
+
+		norm := 0.0
+		for i := j; i < m; i++ {
+			norm += a[i*n+j] * a[i*n+j]
+		}
+		norm = math.Sqrt(norm)
+		if norm == 0 {
+			continue
+		}
+
+		// reflect x = a[j:, j] onto -sign(x0) * |x| * e0, avoiding cancellation
+		alpha := -math.Copysign(norm, a[j*n+j])
+		v := make([]float64, m-j)
+		for i := range v {
+			v[i] = a[(j+i)*n+j]
+		}
+		v[0] -= alpha
+		vnorm := 0.0
+		for _, x := range v {
+			vnorm += x * x
+		}
+		vnorm = math.Sqrt(vnorm)
+		for i := range v {
+			v[i] /= vnorm
+		}
+		vs[j] = v
+
+		reflect(v, a[j*n:], n, j)
+		for i := j + 1; i < m; i++ {
+			a[i*n+j] = 0
+		}
+	}
+	return vs
+}
+
+// reflect applies I - 2 v v^T to the rows of the matrix a with the
+// given number of columns, from column c0 onwards
+func reflect(v, a []float64, cols, c0 int) {
+	for c := c0; c < cols; c++ {
+		dot := 0.0
+		for i, vi := range v {
+			dot += vi * a[i*cols+c]
+		}
+		if dot == 0 {
+			continue
+		}
+		dot *= 2
+		for i, vi := range v {
+			a[i*cols+c] -= dot * vi
+		}
+	}
+}
+
+// householderQ builds the first cols columns of the m x m orthogonal
+// matrix H0 @ H1 @ ... of the reflections vs
+func householderQ(vs [][]float64, m, cols int) []float64 {
+	q := make([]float64, m*cols)
+	for i := 0; i < min(m, cols); i++ {
+		q[i*cols+i] = 1
+	}
+	for j := len(vs) - 1; j >= 0; j-- {
+		if vs[j] != nil {
+			reflect(vs[j], q[j*cols:], cols, 0)
+		}
+	}
+	return q
+}
+
+/*
+TryQR is the QR factorisation of the matrices of a (..., m, n) computed
+with Householder reflections, so that
+
+	a = q @ r
+
+where q has orthonormal columns and r is upper triangular. mode selects
+the reduced or complete factorisation.
+*/
+func TryQR[T ng.Float](a *ng.Array[T], mode QRMode) (q, r *ng.Array[T], err error) {
+	if err := checkMatrices(a, false, "QR"); err != nil {
+		return nil, nil, err
+	}
+	if mode != Reduced && mode != Complete {
+		return nil, nil, &ng.ValueError{Op: "QR", Msg: fmt.Sprintf("unknown mode %d", mode)}
+	}
+
+	mats := toMatrices(a, nil)
+	m, n := mats.m, mats.n
+	rows := min(m, n)
+	if mode == Complete {
+		rows = m
+	}
+
+	qData := make([]float64, 0, mats.count()*m*rows)
+	rData := make([]float64, 0, mats.count()*rows*n)
+	for b := 0; b < mats.count(); b++ {
+		mat := mats.at(b)
+		vs := householderQR(mat, m, n, nil)
+		qData = append(qData, householderQ(vs, m, rows)...)
+		rData = append(rData, mat[:rows*n]...)
+	}
+
+	return fromFloat64[T](qData, batchShape(mats.head, m, rows)), fromFloat64[T](rData, batchShape(mats.head, rows, n)), nil
+}
+
+// QR is like TryQR but panics on error
+func QR[T ng.Float](a *ng.Array[T], mode QRMode) (q, r *ng.Array[T]) {
+	q, r, err := TryQR(a, mode)
+	if err != nil {
+		panic(err)
+	}
+	return q, r
+}
+
+/*
+TryCholesky is the Cholesky factorisation of the symmetric positive
+definite matrices of a (..., n, n), the lower triangular l with
+
+	a = l @ l^T
+
+Only the lower triangle of a is read. A NotPositiveDefiniteError is
+returned when a matrix is not positive definite.
+*/
+func TryCholesky[T ng.Float](a *ng.Array[T]) (*ng.Array[T], error) {
+	if err := checkMatrices(a, true, "Cholesky"); err != nil {
+		return nil, err
+	}
+
+	mats := toMatrices(a, nil)
+	n := mats.n
+	l := make([]float64, len(mats.data))
+	for b := 0; b < mats.count(); b++ {
+		mat, lb := mats.at(b), l[b*n*n:(b+1)*n*n]
+		for j := 0; j < n; j++ {
+			d := mat[j*n+j]
+			for k := 0; k < j; k++ {
+				d -= lb[j*n+k] * lb[j*n+k]
+			}
+			if !(d > 0) {
+				return nil, &NotPositiveDefiniteError{Op: "Cholesky", Index: b}
+			}
+			d = math.Sqrt(d)
+			lb[j*n+j] = d
+
+			for i := j + 1; i < n; i++ {
+				s := mat[i*n+j]
+				for k := 0; k < j; k++ {
+					s -= lb[i*n+k] * lb[j*n+k]
+				}
+				lb[i*n+j] = s / d
+			}
+		}
+	}
+	return fromFloat64[T](l, batchShape(mats.head, n, n)), nil
+}
+
+// Cholesky is like TryCholesky but panics on error
+func Cholesky[T ng.Float](a *ng.Array[T]) *ng.Array[T] {
+	return must(TryCholesky(a))
+}
+
+/*
+TryLstsq solves the least-squares problem min |a @ x - b| for x, where
+a is (..., m, n) and b is either (..., m, k) or a vector (m,). The
+batch axes of a and b are broadcast together.
+
+It uses a QR factorisation with column pivoting. It returns the solution
+x of shape (..., n, k) (or (..., n) for a vector b), the sum of squared
+residuals of every column of b of shape (..., k) (or the batch shape),
+and the effective rank of every matrix of a, the number of diagonal
+entries of the pivoted r above max(m, n) * eps times the largest one.
+For rank deficient matrices x is a basic solution with n - rank zeros.
+*/
+func TryLstsq[T ng.Float](a, b *ng.Array[T]) (x, residuals *ng.Array[T], rank *ng.Array[int], err error) {
+	if err := checkMatrices(a, false, "Lstsq"); err != nil {
+		return nil, nil, nil, err
+	}
+	m, n := a.Shape[a.Ndim-2], a.Shape[a.Ndim-1]
+
+	vector := b.Ndim == 1
+	if vector {
+		b = b.Reshape([]int{b.Shape[0], 1})
+	} else if err := checkMatrices(b, false, "Lstsq"); err != nil {
+		return nil, nil, nil, err
+	}
+	if b.Shape[b.Ndim-2] != m {
+		return nil, nil, nil, &ng.ShapeError{Op: "Lstsq", Shape: b.Shape, Msg: "first dimension of the right hand side must match the rows of the matrices"}
+	}
+
+	head, err := ng.TryBroadcastShapes(a.Shape[:a.Ndim-2], b.Shape[:b.Ndim-2])
+	if err != nil {
+		return nil, nil, nil, &ng.BroadcastError{Op: "Lstsq", Shapes: [][]int{a.Shape, b.Shape}}
+	}
+	as, bs := toMatrices(a, head), toMatrices(b, head)
+	k := bs.n
+	count := as.count()
+
+	xData := make([]float64, count*n*k)
+	resData := make([]float64, count*k)
+	rankData := make([]int, count)
+	perm := make([]int, n)
+	qtb := make([]float64, m*k)
+	for i := 0; i < count; i++ {
+		orig := append([]float64{}, as.at(i)...)
+		r := as.at(i)
+		vs := householderQR(r, m, n, perm)
+
+		// effective rank from the diagonal of r, which is decreasing
+		// in magnitude thanks to the pivoting
+		rk := 0
+		if len(vs) > 0 {
+			tol := float64(max(m, n)) * eps * math.Abs(r[0])
+			for rk < len(vs) && math.Abs(r[rk*n+rk]) > tol {
+				rk++
+			}
+		}
+		rankData[i] = rk
+
+		// q^T @ b, then back substitution with the leading rk x rk block of r
+		copy(qtb, bs.at(i))
+		for j, v := range vs {
+			if v != nil {
+				reflect(v, qtb[j*k:], k, 0)
+			}
+		}
+		xi := xData[i*n*k : (i+1)*n*k]
+		for row := rk - 1; row >= 0; row-- {
+			for c := 0; c < k; c++ {
+				s := qtb[row*k+c]
+				for j := row + 1; j < rk; j++ {
+					s -= r[row*n+j] * xi[perm[j]*k+c]
+				}
+				xi[perm[row]*k+c] = s / r[row*n+row]
+			}
+		}
+
+		// squared residuals of the columns of b
+		bi := bs.at(i)
+		for row := 0; row < m; row++ {
+			for c := 0; c < k; c++ {
+				d := bi[row*k+c]
+				for j := 0; j < n; j++ {
+					d -= orig[row*n+j] * xi[j*k+c]
+				}
+				resData[i*k+c] += d * d
+			}
+		}
+	}
+
+	rankShape := batchShape(head)
+	if len(head) == 0 {
+		rankShape = []int{1}
+	}
+	rank = ng.NewArrayFromShape[int](rankShape)
+	copy(rank.Data, rankData)
+
+	if vector {
+		return fromFloat64[T](xData, batchShape(head, n)), fromFloat64[T](resData, head), rank, nil
+	}
+	return fromFloat64[T](xData, batchShape(head, n, k)), fromFloat64[T](resData, batchShape(head, k)), rank, nil
+}
+
+// Lstsq is like TryLstsq but panics on error
+func Lstsq[T ng.Float](a, b *ng.Array[T]) (x, residuals *ng.Array[T], rank *ng.Array[int]) {
+	x, residuals, rank, err := TryLstsq(a, b)
+	if err != nil {
+		panic(err)
+	}
+	return x, residuals, rank
+}
@@ -0,0 +1,451 @@
+package linalg
+
+import (
+	"math"
+	"sort"
+
+	ng "ndgo/ndgo"
+)
+
+// upper bound on the sweeps of the Jacobi methods, which converge
+// quadratically and need far fewer in practice
+const JACOBI_MAX_SWEEPS int = 100
+
+// machine epsilon of float64, in which the computations run
+const eps = 0x1p-52
+
+// machine epsilon of T, the relative precision of the data of an Array
+// and so of the singular values which can be told apart from zero
+func epsilon[T ng.Float]() float64 {
+	if _, ok := any(T(0)).(float32); ok {
+		return 0x1p-23
+	}
+	return eps
+}
+
+// rotates columns p and q of the matrix a with the given number of
+// columns: (a_p, a_q) = (c a_p - s a_q, s a_p + c a_q)
+func rotateColumns(a []float64, cols, p, q int, c, s float64) {
+	for i := p; i < len(a); i += cols {
+		ap, aq := a[i], a[i-p+q]
+		a[i] = c*ap - s*aq
+		a[i-p+q] = s*ap + c*aq
+	}
+}
+
+// identity matrix of size n, row major
+func identity(n int) []float64 {
+	eye := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		eye[i*n+i] = 1
+	}
+	return eye
+}
+
+/*
+orderColumns sorts values and reorders the columns of the matrices
+alongside, in decreasing order of values, or increasing when ascending
+is set.
+*/
+func orderColumns(values []float64, ascending bool, mats ...[]float64) {
+	k := len(values)
+	if k == 0 {
+		return
+	}
+	order := make([]int, k)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if ascending {
+			return values[order[i]] < values[order[j]]
+		}
+		return values[order[i]] > values[order[j]]
+	})
+
+	sorted := make([]float64, k)
+	for i, o := range order {
+		sorted[i] = values[o]
+	}
+	copy(values, sorted)
+
+	// every matrix has one column per value
+	for _, mat := range mats {
+		src := append([]float64{}, mat...)
+		for r := 0; r < len(mat)/k; r++ {
+			for i, o := range order {
+				mat[r*k+i] = src[r*k+o]
+			}
+		}
+	}
+}
+
+/*
+completeBasis replaces the zero columns of the m x cols matrix u by
+unit vectors orthogonal to all the others, taken from the standard
+basis with Gram-Schmidt. The nonzero columns must be orthonormal.
+*/
+func completeBasis(u []float64, m, cols int) {
+	col := make([]float64, m)
+	next := 0
+	for j := 0; j < cols; j++ {
+		norm := 0.0
+		for i := 0; i < m; i++ {
+			norm += u[i*cols+j] * u[i*cols+j]
+		}
+		if norm > 0 {
+			continue
+		}
+
+		for ; next < m; next++ {
+			for i := range col {
+				col[i] = 0
+			}
+			col[next] = 1
+
+			// orthogonalise twice against every column, for stability
+			for pass := 0; pass < 2; pass++ {
+				for c := 0; c < cols; c++ {
+					dot := 0.0
+					for i := 0; i < m; i++ {
+						dot += u[i*cols+c] * col[i]
+					}
+					for i := 0; i < m; i++ {
+						col[i] -= dot * u[i*cols+c]
+					}
+				}
+			}
+
+			norm = 0
+			for _, x := range col {
+				norm += x * x
+			}
+			if norm > 0.25 {
+				break
+			}
+		}
+		norm = math.Sqrt(norm)
+		for i := 0; i < m; i++ {
+			u[i*cols+j] = col[i] / norm
+		}
+		next++
+	}
+}
+
+/*
+svdTall is the singular value decomposition of the m x n matrix a with
+m >= n, by one-sided Jacobi rotations which orthogonalise the columns
+of a. It returns u (m x n, or m x m when full), the n singular values
+in decreasing order, and v (n x n) with a = u @ diag(s) @ v^T. Columns
+of u whose singular value is zero up to the precision prec of the data
+are completed to an orthonormal basis.
+*/
+func svdTall(a []float64, m, n int, full bool, prec float64) (u, s, v []float64) {
+	w := append([]float64{}, a...)
+	v = identity(n)
+
+	for sweep := 0; sweep < JACOBI_MAX_SWEEPS; sweep++ {
+		rotated := false
+		for p := 0; p < n-1; p++ {
+			for q := p + 1; q < n; q++ {
+				alpha, beta, gamma := 0.0, 0.0, 0.0
+				for i := 0; i < m; i++ {
+					wp, wq := w[i*n+p], w[i*n+q]
+					alpha += wp * wp
+					beta += wq * wq
+					gamma += wp * wq
+				}
+				if gamma == 0 || math.Abs(gamma) <= eps*math.Sqrt(alpha*beta) {
+					continue
+				}
+				rotated = true
+
+				zeta := (beta - alpha) / (2 * gamma)
+				t := math.Copysign(1, zeta) / (math.Abs(zeta) + math.Sqrt(1+zeta*zeta))
+				c := 1 / math.Sqrt(1+t*t)
+				rotateColumns(w, n, p, q, c, c*t)
+				rotateColumns(v, n, p, q, c, c*t)
+			}
+		}
+		if !rotated {
+			break
+		}
+	}
+
+	// the singular values are the norms of the orthogonal columns
+	s = make([]float64, n)
+	for j := 0; j < n; j++ {
+		for i := 0; i < m; i++ {
+			s[j] += w[i*n+j] * w[i*n+j]
+		}
+		s[j] = math.Sqrt(s[j])
+	}
+	orderColumns(s, false, w, v)
+
+	cols := n
+	if full {
+		cols = m
+	}
+	u = make([]float64, m*cols)
+	tol := rankTolerance(s, m, n, prec)
+	for j := 0; j < n; j++ {
+		if s[j] <= tol || s[j] == 0 {
+			continue
+		}
+		for i := 0; i < m; i++ {
+			u[i*cols+j] = w[i*n+j] / s[j]
+		}
+	}
+	completeBasis(u, m, cols)
+	return u, s, v
+}
+
+// transpose of the m x n matrix a
+func transposed(a []float64, m, n int) []float64 {
+	t := make([]float64, len(a))
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			t[j*m+i] = a[i*n+j]
+		}
+	}
+	return t
+}
+
+/*
+svd is the singular value decomposition of the m x n matrix a. It
+returns u (m x k, or m x m when full), the k = min(m, n) singular
+values in decreasing order, and vt (k x n, or n x n when full). The
+data of a is taken as float64.
+*/
+func svd(a []float64, m, n int, full bool) (u, s, vt []float64) {
+	if m >= n {
+		u, s, v := svdTall(a, m, n, full, eps)
+		return u, s, transposed(v, n, n)
+	}
+	// a^T = u' s v'^T, so a = v' s u'^T
+	ut, s, v := svdTall(transposed(a, m, n), n, m, full, eps)
+	cols := m
+	if full {
+		cols = n
+	}
+	return v, s, transposed(ut, n, cols)
+}
+
+/*
+TrySVD is the singular value decomposition of the matrices of a
+(..., m, n), computed with one-sided Jacobi rotations, so that
+
+	a = u @ diag(s) @ vt
+
+with k = min(m, n) singular values in s (..., k) in decreasing order.
+When full is set u is (..., m, m) and vt is (..., n, n), otherwise the
+thin factors u (..., m, k) and vt (..., k, n) are returned.
+*/
+func TrySVD[T ng.Float](a *ng.Array[T], full bool) (u, s, vt *ng.Array[T], err error) {
+	if err := checkMatrices(a, false, "SVD"); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// the Jacobi rotations need tall matrices: a^T = u' s v'^T, so
+	// a = v' s u'^T
+	wide := a.Shape[a.Ndim-2] < a.Shape[a.Ndim-1]
+	if wide {
+		a = transposeMatrices(a)
+	}
+
+	mats := toMatrices(a, nil)
+	m, n := mats.m, mats.n
+	ucols := n
+	if full {
+		ucols = m
+	}
+
+	var uData, sData, vData []float64
+	for b := 0; b < mats.count(); b++ {
+		ub, sb, vb := svdTall(mats.at(b), m, n, full, epsilon[T]())
+		uData = append(uData, ub...)
+		sData = append(sData, sb...)
+		vData = append(vData, vb...)
+	}
+
+	u = fromFloat64[T](uData, batchShape(mats.head, m, ucols))
+	s = fromFloat64[T](sData, batchShape(mats.head, n))
+	v := fromFloat64[T](vData, batchShape(mats.head, n, n))
+	if wide {
+		return v, s, transposeMatrices(u).Copy(), nil
+	}
+	return u, s, transposeMatrices(v).Copy(), nil
+}
+
+// SVD is like TrySVD but panics on error
+func SVD[T ng.Float](a *ng.Array[T], full bool) (u, s, vt *ng.Array[T]) {
+	u, s, vt, err := TrySVD(a, full)
+	if err != nil {
+		panic(err)
+	}
+	return u, s, vt
+}
+
+/*
+eighJacobi computes the eigenvalues, in increasing order, and the
+eigenvectors, as the columns of v, of the symmetric n x n matrix a by
+cyclic Jacobi rotations. a is overwritten.
+*/
+func eighJacobi(a []float64, n int) (w, v []float64) {
+	v = identity(n)
+
+	for sweep := 0; sweep < JACOBI_MAX_SWEEPS; sweep++ {
+		off, diag := 0.0, 0.0
+		for i := 0; i < n; i++ {
+			diag += a[i*n+i] * a[i*n+i]
+			for j := i + 1; j < n; j++ {
+				off += a[i*n+j] * a[i*n+j]
+			}
+		}
+		if off <= eps*eps*diag || off == 0 {
+			break
+		}
+
+		for p := 0; p < n-1; p++ {
+			for q := p + 1; q < n; q++ {
+				apq := a[p*n+q]
+				if apq == 0 {
+					continue
+				}
+				theta := (a[q*n+q] - a[p*n+p]) / (2 * apq)
+				t := math.Copysign(1, theta) / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				c := 1 / math.Sqrt(1+t*t)
+				s := c * t
+
+				// a = j^T a j, on the columns and then on the rows
+				rotateColumns(a, n, p, q, c, s)
+				for k := 0; k < n; k++ {
+					ap, aq := a[p*n+k], a[q*n+k]
+					a[p*n+k] = c*ap - s*aq
+					a[q*n+k] = s*ap + c*aq
+				}
+				rotateColumns(v, n, p, q, c, s)
+			}
+		}
+	}
+
+	w = make([]float64, n)
+	for i := range w {
+		w[i] = a[i*n+i]
+	}
+	orderColumns(w, true, v)
+	return w, v
+}
+
+/*
+TryEighSymmetric returns the eigenvalues w (..., n), in increasing
+order, and the eigenvectors v (..., n, n) of the symmetric matrices of
+a (..., n, n), computed with Jacobi rotations. The columns of v are
+the orthonormal eigenvectors, so that
+
+	a = v @ diag(w) @ v^T
+
+Only the lower triangle of a is read.
+*/
+func TryEighSymmetric[T ng.Float](a *ng.Array[T]) (w, v *ng.Array[T], err error) {
+	if err := checkMatrices(a, true, "EighSymmetric"); err != nil {
+		return nil, nil, err
+	}
+
+	// the lower triangle mirrored above the diagonal
+	n := a.Shape[a.Ndim-1]
+	rows := ng.Arange(0, n, 1).Reshape([]int{n, 1})
+	lower := ng.GreaterEqual(rows, rows.Reshape([]int{1, n}))
+	mats := toMatrices(ng.Where(lower, a, transposeMatrices(a)), nil)
+
+	var wData, vData []float64
+	for b := 0; b < mats.count(); b++ {
+		wb, vb := eighJacobi(mats.at(b), n)
+		wData = append(wData, wb...)
+		vData = append(vData, vb...)
+	}
+	return fromFloat64[T](wData, batchShape(mats.head, n)), fromFloat64[T](vData, batchShape(mats.head, n, n)), nil
+}
+
+// EighSymmetric is like TryEighSymmetric but panics on error
+func EighSymmetric[T ng.Float](a *ng.Array[T]) (w, v *ng.Array[T]) {
+	w, v, err := TryEighSymmetric(a)
+	if err != nil {
+		panic(err)
+	}
+	return w, v
+}
+
+// singular values below this are treated as zero, the sorted s comes
+// from an m x n matrix whose data has the machine epsilon prec
+func rankTolerance(s []float64, m, n int, prec float64) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	return float64(max(m, n)) * prec * s[0]
+}
+
+/*
+nonzeroSingular reports which of the singular values s (..., k) of
+(..., m, n) matrices are above max(m, n) * prec times the largest one,
+the others are treated as zero by MatrixRank and Pinv. prec is the
+machine epsilon of the element type of the matrices.
+*/
+func nonzeroSingular(s *ng.Array[float64], m, n int, prec float64) *ng.Array[bool] {
+	k := s.Shape[s.Ndim-1]
+	ranges := make([]ng.Range, s.Ndim)
+	for i := range ranges {
+		ranges[i] = ng.FullRange()
+	}
+	ranges[s.Ndim-1] = ng.Span(0, min(k, 1))
+	// the values are sorted, the first is the largest
+	tol := ng.Mul(s.Slice(ranges...), ng.Full([]int{1}, float64(max(m, n))*prec))
+	return ng.Greater(s, tol)
+}
+
+/*
+TryMatrixRank is the rank of the matrices of a (..., m, n), the number
+of singular values above max(m, n) * eps times the largest one, where
+eps is the machine epsilon of T. The result has the batch shape of a,
+or shape [1] for a single matrix.
+*/
+func TryMatrixRank[T ng.Float](a *ng.Array[T]) (*ng.Array[int], error) {
+	if err := checkMatrices(a, false, "MatrixRank"); err != nil {
+		return nil, err
+	}
+	_, s, _ := SVD(ng.AsType[float64](a), false)
+	m, n := a.Shape[a.Ndim-2], a.Shape[a.Ndim-1]
+	ones := ng.Where(nonzeroSingular(s, m, n, epsilon[T]()), ng.Ones[int]([]int{1}), ng.Zeros[int]([]int{1}))
+	return ng.Sum(ones, []int{-1}, false), nil
+}
+
+// MatrixRank is like TryMatrixRank but panics on error
+func MatrixRank[T ng.Float](a *ng.Array[T]) *ng.Array[int] {
+	return must(TryMatrixRank(a))
+}
+
+/*
+TryPinv is the Moore-Penrose pseudo-inverse (..., n, m) of the matrices
+of a (..., m, n), computed from their SVD as v @ diag(1/s) @ u^T.
+Singular values below the tolerance of MatrixRank, which depends on T,
+are treated as zero.
+*/
+func TryPinv[T ng.Float](a *ng.Array[T]) (*ng.Array[T], error) {
+	if err := checkMatrices(a, false, "Pinv"); err != nil {
+		return nil, err
+	}
+	// computed in float64 and converted to T once
+	u, s, vt := SVD(ng.AsType[float64](a), false)
+	m, n := a.Shape[a.Ndim-2], a.Shape[a.Ndim-1]
+
+	// 1/s as a row (..., 1, k), scaling the columns of v
+	sInv := ng.Where(nonzeroSingular(s, m, n, epsilon[T]()), ng.Div(ng.Ones[float64]([]int{1}), s), ng.Zeros[float64]([]int{1}))
+	sInv = sInv.Reshape(batchShape(s.Shape[:s.Ndim-1], 1, s.Shape[s.Ndim-1]))
+	pinv := ng.Matmul(ng.Mul(transposeMatrices(vt), sInv), transposeMatrices(u))
+	return fromFloat64[T](pinv.Data, pinv.Shape), nil
+}
+
+// Pinv is like TryPinv but panics on error
+func Pinv[T ng.Float](a *ng.Array[T]) *ng.Array[T] {
+	return must(TryPinv(a))
+}
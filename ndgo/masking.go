@@ -0,0 +1,144 @@
+package ndgo
+
+import (
+	"fmt"
+)
+
+// Selection with boolean masks
+// ----------------------------------------------------------------
+
+// broadcasts mask to the shape of arr, the shape of arr itself must not change
+func broadcastMask[T Elem](arr *Array[T], mask *Array[bool], name string) (*Array[bool], error) {
+	shape, err := broadcastShapes(arr.Shape, mask.Shape)
+	if err != nil || !CheckShapesEqual(shape, arr.Shape) {
+		return nil, &BroadcastError{Op: name, Shapes: [][]int{mask.Shape, arr.Shape}}
+	}
+	return broadcastArray(mask, shape), nil
+}
+
+/*
+TryWhere returns the elements of x where cond is true and the elements
+of y elsewhere. cond, x and y are broadcast together to the shape of
+the result.
+*/
+func TryWhere[T Elem](cond *Array[bool], x, y *Array[T]) (*Array[T], error) {
+	shape, err := broadcastShapes(cond.Shape, x.Shape)
+	if err == nil {
+		shape, err = broadcastShapes(shape, y.Shape)
+	}
+	if err != nil {
+		return nil, &BroadcastError{Op: "Where", Shapes: [][]int{cond.Shape, x.Shape, y.Shape}}
+	}
+
+	c := broadcastArray(cond, shape)
+	xb := broadcastArray(x, shape)
+	yb := broadcastArray(y, shape)
+	res := NewArrayFromShape[T](shape)
+
+	where := func(s, e int) {
+		itc, itx, ity := c.Iter(), xb.Iter(), yb.Iter()
+		itc.Seek(s)
+		itx.Seek(s)
+		ity.Seek(s)
+		for i := s; i < e; i++ {
+			itc.Next()
+			itx.Next()
+			ity.Next()
+			if c.Data[itc.Index()] {
+				res.Data[i] = xb.Data[itx.Index()]
+			} else {
+				res.Data[i] = yb.Data[ity.Index()]
+			}
+		}
+	}
+
+	if res.Totalsize >= PARALLEL_BOUNDARY {
+		parallelFor(res.Totalsize, where)
+	} else {
+		where(0, res.Totalsize)
+	}
+	return res, nil
+}
+
+// Where is like TryWhere but panics on error
+func Where[T Elem](cond *Array[bool], x, y *Array[T]) *Array[T] {
+	return must(TryWhere(cond, x, y))
+}
+
+// TryClip limits the values of arr to the interval [lo, hi], NaNs are kept
+func TryClip[T Numeric](arr *Array[T], lo, hi T) (*Array[T], error) {
+	if lo > hi {
+		return nil, &ValueError{Op: "Clip", Msg: fmt.Sprintf("lo %v must not be greater than hi %v", lo, hi)}
+	}
+	return Apply(arr, func(x T) T {
+		if x < lo {
+			return lo
+		}
+		if x > hi {
+			return hi
+		}
+		return x
+	}), nil
+}
+
+// Clip is like TryClip but panics on error
+func Clip[T Numeric](arr *Array[T], lo, hi T) *Array[T] {
+	return must(TryClip(arr, lo, hi))
+}
+
+/*
+TryMaskedSelect returns a new 1-D Array holding the elements of arr where
+mask is true, in row major order. The mask is broadcast to the shape
+of arr.
+*/
+func TryMaskedSelect[T Elem](arr *Array[T], mask *Array[bool]) (*Array[T], error) {
+	m, err := broadcastMask(arr, mask, "MaskedSelect")
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]T, 0)
+	itm := m.Iter()
+	for it := arr.Iter(); it.Next(); {
+		itm.Next()
+		if m.Data[itm.Index()] {
+			values = append(values, arr.Data[it.Index()])
+		}
+	}
+
+	res := NewArrayFromShape[T]([]int{len(values)})
+	res.FromValues(values)
+	return res, nil
+}
+
+// MaskedSelect is like TryMaskedSelect but panics on error
+func MaskedSelect[T Elem](arr *Array[T], mask *Array[bool]) *Array[T] {
+	return must(TryMaskedSelect(arr, mask))
+}
+
+/*
+TryMaskedAssign sets the elements of arr where mask is true to value,
+in-place. The mask is broadcast to the shape of arr, and views such as
+transposed arrays write through to the data they share.
+*/
+func TryMaskedAssign[T Elem](arr *Array[T], mask *Array[bool], value T) error {
+	m, err := broadcastMask(arr, mask, "MaskedAssign")
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	for it := m.Iter(); it.Next(); i++ {
+		if m.Data[it.Index()] {
+			arr.Set(i, value)
+		}
+	}
+	return nil
+}
+
+// MaskedAssign is like TryMaskedAssign but panics on error
+func MaskedAssign[T Elem](arr *Array[T], mask *Array[bool], value T) {
+	if err := TryMaskedAssign(arr, mask, value); err != nil {
+		panic(err)
+	}
+}
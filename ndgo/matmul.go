@@ -0,0 +1,412 @@
+package ndgo
+
+import (
+	"fmt"
+)
+
+// block sizes of the matmul kernel, a packed panel of b holds
+// MATMUL_BLOCK_K x MATMUL_BLOCK_N elements and stays in cache while
+// it is multiplied with MATMUL_BLOCK_M rows of a
+const (
+	MATMUL_BLOCK_M int = 64
+	MATMUL_BLOCK_K int = 128
+	MATMUL_BLOCK_N int = 256
+)
+
+/*
+matmulPlan holds the operands of a (batched) matrix multiplication
+(..., m, n) @ (..., n, p) after they have been made contiguous, along
+with the element offset of every batch in a, b and the result.
+*/
+type matmulPlan[T Numeric] struct {
+	a, b, res *Array[T]
+	m, n, p   int
+	aOffsets  []int
+	bOffsets  []int
+}
+
+// makes arr C-contiguous, copying it only when it is not already
+func contiguous[T Elem](arr *Array[T]) *Array[T] {
+	if arr.C_ORDER {
+		return arr
+	}
+	return arr.Copy()
+}
+
+/*
+batchOffsets returns, for every batch of the broadcasted head shape,
+the offset (in elements) of the matrix of arr used by that batch.
+arr must be C-contiguous, broadcasted head axes get a stride of 0.
+*/
+func batchOffsets[T Numeric](arr *Array[T], head []int) []int {
+	shape := append(append([]int{}, head...), arr.Shape[arr.Ndim-2:]...)
+	view := broadcastArray(arr, shape)
+
+	nbatch := 1
+	for _, v := range head {
+		nbatch *= v
+	}
+
+	offsets := make([]int, nbatch)
+	coords := make([]int, len(head))
+	for batch := range offsets {
+		unravelIndex(batch, head, coords)
+		offset := view.Offset
+		for d, c := range coords {
+			offset += c * view.Strides[d]
+		}
+		offsets[batch] = offset / view.Itemsize
+	}
+	return offsets
+}
+
+/*
+matmulRows computes rows [i0, i1) of one batch of the result. Panels
+of b are packed into the contiguous buffer panel, and every row of a
+is multiplied with the whole panel before moving on, so both the
+panel and the rows of the result stay in cache.
+*/
+func (plan *matmulPlan[T]) matmulRows(batch, i0, i1 int, panel []T) {
+	m, n, p := plan.m, plan.n, plan.p
+	a := plan.a.Data[plan.aOffsets[batch]:]
+	b := plan.b.Data[plan.bOffsets[batch]:]
+	c := plan.res.Data[batch*m*p:]
+
+	for j0 := 0; j0 < p; j0 += MATMUL_BLOCK_N {
+		j1 := min(j0+MATMUL_BLOCK_N, p)
+		nc := j1 - j0
+
+		for k0 := 0; k0 < n; k0 += MATMUL_BLOCK_K {
+			k1 := min(k0+MATMUL_BLOCK_K, n)
+
+			// pack b[k0:k1, j0:j1] into a contiguous panel
+			for k := k0; k < k1; k++ {
+				copy(panel[(k-k0)*nc:(k-k0+1)*nc], b[k*p+j0:k*p+j1])
+			}
+
+			for i := i0; i < i1; i++ {
+				crow := c[i*p+j0 : i*p+j1]
+				arow := a[i*n : (i+1)*n]
+				for k := k0; k < k1; k++ {
+					aik := arow[k]
+					brow := panel[(k-k0)*nc : (k-k0+1)*nc]
+					for j, bkj := range brow {
+						crow[j] += aik * bkj
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+TryMatmul is the matrix multiplication of n-dimensional arrays.
+
+for 2D arrays (m, n) @ (n, d) = (m, d)
+for nD arrays (..., m, n) @ (..., n, d) = (..., m, d)
+
+when the dimensions of arrays are greater than 2, we do N matmuls
+on the last two axes of the operands. These N matmuls will be stacked
+in the shape of the higher dimensions, which are broadcast together.
+
+1D operands follow numpy: a 1D first operand is promoted to a matrix
+by prepending a unit dimension, a 1D second operand by appending one,
+and the added dimensions are removed from the result. For two 1D
+operands the result has shape [1].
+
+Operands that are not C-contiguous are packed first, and the work is
+split between goroutines over batches and blocks of rows.
+*/
+func TryMatmul[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	if a.Ndim == 1 || b.Ndim == 1 {
+		return matmulVector(a, b)
+	}
+	if a.Shape[a.Ndim-1] != b.Shape[b.Ndim-2] {
+		return nil, &ShapeError{Op: "Matmul", Shape: b.Shape, Msg: fmt.Sprintf("second-last dimension must match last dimension %d of first array", a.Shape[a.Ndim-1])}
+	}
+
+	// broadcast result shape untill last two axes
+	a_shape_head := a.Shape[:a.Ndim-2]
+	b_shape_head := b.Shape[:b.Ndim-2]
+
+	res_shape_head, err := broadcastShapes(a_shape_head, b_shape_head)
+	if err != nil {
+		return nil, &BroadcastError{Op: "Matmul", Shapes: [][]int{a_shape_head, b_shape_head}}
+	}
+
+	m := a.Shape[a.Ndim-2]
+	n := a.Shape[a.Ndim-1]
+	p := b.Shape[b.Ndim-1]
+
+	result_shape := append(append([]int{}, res_shape_head...), m, p)
+	result := NewArrayFromShape[T](result_shape)
+	if result.Totalsize == 0 || n == 0 {
+		return result, nil
+	}
+
+	// fast path, C-contiguous operands are used as they are
+	ac, bc := contiguous(a), contiguous(b)
+	plan := &matmulPlan[T]{
+		a: ac, b: bc, res: result,
+		m: m, n: n, p: p,
+		aOffsets: batchOffsets(ac, res_shape_head),
+		bOffsets: batchOffsets(bc, res_shape_head),
+	}
+
+	nbatch := len(plan.aOffsets)
+	rowBlocks := (m + MATMUL_BLOCK_M - 1) / MATMUL_BLOCK_M
+	ntasks := nbatch * rowBlocks
+
+	run := func(s, e int) {
+		panel := make([]T, min(MATMUL_BLOCK_K, n)*min(MATMUL_BLOCK_N, p))
+		for task := s; task < e; task++ {
+			batch, block := task/rowBlocks, task%rowBlocks
+			i0 := block * MATMUL_BLOCK_M
+			plan.matmulRows(batch, i0, min(i0+MATMUL_BLOCK_M, m), panel)
+		}
+	}
+
+	if nbatch*m*n*p >= PARALLEL_BOUNDARY {
+		parallelFor(ntasks, run)
+	} else {
+		run(0, ntasks)
+	}
+	return result, nil
+}
+
+// Matmul is like TryMatmul but panics on error
+func Matmul[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryMatmul(a, b))
+}
+
+// promotes 1D operands of Matmul to matrices and removes
+// the added unit dimensions from the result
+func matmulVector[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	// checked before the promotion so that the error reports the shapes
+	// of the operands as given
+	k := 0
+	if b.Ndim > 1 {
+		k = b.Ndim - 2
+	}
+	if a.Shape[a.Ndim-1] != b.Shape[k] {
+		msg := fmt.Sprintf("second-last dimension must match last dimension %d of first array", a.Shape[a.Ndim-1])
+		if b.Ndim == 1 {
+			msg = fmt.Sprintf("length must match last dimension %d of first array", a.Shape[a.Ndim-1])
+		}
+		return nil, &ShapeError{Op: "Matmul", Shape: b.Shape, Msg: msg}
+	}
+
+	a2, b2 := a, b
+	if a.Ndim == 1 {
+		a2 = a.view([]int{1, a.Shape[0]}, []int{0, a.Strides[0]}, a.Offset)
+	}
+	if b.Ndim == 1 {
+		b2 = b.view([]int{b.Shape[0], 1}, []int{b.Strides[0], 0}, b.Offset)
+	}
+
+	res, err := TryMatmul(a2, b2)
+	if err != nil {
+		return nil, err
+	}
+
+	shape := append([]int{}, res.Shape[:res.Ndim-2]...)
+	if a.Ndim != 1 {
+		shape = append(shape, res.Shape[res.Ndim-2])
+	}
+	if b.Ndim != 1 {
+		shape = append(shape, res.Shape[res.Ndim-1])
+	}
+	if len(shape) == 0 {
+		shape = append(shape, 1)
+	}
+	return res.Reshape(shape), nil
+}
+
+/*
+TryDot is the dot product of two arrays, like numpy.dot.
+
+For arrays of at most 2 dimensions, and when b is 1D, it is the same
+as Matmul. Otherwise it is a sum product over the last axis of a and
+the second-last axis of b:
+
+	dot(a, b)[i, j, k, m] = sum(a[i, j, :] * b[k, :, m])
+*/
+func TryDot[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	if (a.Ndim <= 2 && b.Ndim <= 2) || b.Ndim == 1 {
+		return TryMatmul(a, b)
+	}
+
+	n := a.Shape[a.Ndim-1]
+	if n != b.Shape[b.Ndim-2] {
+		return nil, &ShapeError{Op: "Dot", Shape: b.Shape, Msg: fmt.Sprintf("second-last dimension must match last dimension %d of first array", n)}
+	}
+
+	// bring the contracted axis of b to the front
+	perm := make([]int, 0, b.Ndim)
+	perm = append(perm, b.Ndim-2)
+	for ax := 0; ax < b.Ndim; ax++ {
+		if ax != b.Ndim-2 {
+			perm = append(perm, ax)
+		}
+	}
+
+	a2 := a.Reshape([]int{ShapeSize(a.Shape[:a.Ndim-1]), n})
+	b2 := b.Transpose(perm).Reshape([]int{n, ShapeSize(b.Shape[:b.Ndim-2]) * b.Shape[b.Ndim-1]})
+
+	shape := append([]int{}, a.Shape[:a.Ndim-1]...)
+	shape = append(shape, b.Shape[:b.Ndim-2]...)
+	shape = append(shape, b.Shape[b.Ndim-1])
+	return Matmul(a2, b2).Reshape(shape), nil
+}
+
+// Dot is like TryDot but panics on error
+func Dot[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryDot(a, b))
+}
+
+/*
+TryInner is the sum product over the last axes of a and b, like
+numpy.inner. The result has shape a.Shape[:-1] + b.Shape[:-1], or
+[1] when both arrays are 1D.
+*/
+func TryInner[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	n := a.Shape[a.Ndim-1]
+	if n != b.Shape[b.Ndim-1] {
+		return nil, &ShapeError{Op: "Inner", Shape: b.Shape, Msg: fmt.Sprintf("last dimension must match last dimension %d of first array", n)}
+	}
+
+	a2 := a.Reshape([]int{ShapeSize(a.Shape[:a.Ndim-1]), n})
+	b2 := b.Reshape([]int{ShapeSize(b.Shape[:b.Ndim-1]), n}).Transpose(nil)
+
+	shape := append([]int{}, a.Shape[:a.Ndim-1]...)
+	shape = append(shape, b.Shape[:b.Ndim-1]...)
+	if len(shape) == 0 {
+		shape = append(shape, 1)
+	}
+	return Matmul(a2, b2).Reshape(shape), nil
+}
+
+// Inner is like TryInner but panics on error
+func Inner[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryInner(a, b))
+}
+
+// TryVdot is the dot product of a and b flattened, which must
+// have the same number of elements. The result has shape [1].
+func TryVdot[T Numeric](a, b *Array[T]) (*Array[T], error) {
+	if a.Totalsize != b.Totalsize {
+		return nil, &ShapeError{Op: "Vdot", Shape: b.Shape, Msg: fmt.Sprintf("size must match size %d of first array", a.Totalsize)}
+	}
+	return TryInner(a.Reshape([]int{a.Totalsize}), b.Reshape([]int{b.Totalsize}))
+}
+
+// Vdot is like TryVdot but panics on error
+func Vdot[T Numeric](a, b *Array[T]) *Array[T] {
+	return must(TryVdot(a, b))
+}
+
+// Outer is the outer product of a and b flattened, the result
+// has shape [a.Totalsize, b.Totalsize]
+func Outer[T Numeric](a, b *Array[T]) *Array[T] {
+	return Matmul(a.Reshape([]int{a.Totalsize, 1}), b.Reshape([]int{1, b.Totalsize}))
+}
+
+/*
+TryTensordot is the sum product of a and b over the axes axesA of a and
+axesB of b, like numpy.tensordot. The axes are contracted in pairs, so
+axesA[i] of a is summed against axesB[i] of b, and negative axes count
+from the end.
+
+The result has the remaining axes of a followed by the remaining axes
+of b, or shape [1] when every axis is contracted. Transposed and other
+non-contiguous operands are arranged through their strides, and the
+contraction runs as a single Matmul.
+*/
+func TryTensordot[T Numeric](a, b *Array[T], axesA, axesB []int) (*Array[T], error) {
+	if len(axesA) != len(axesB) {
+		return nil, &ValueError{Op: "Tensordot", Msg: fmt.Sprintf("%d axes of the first array paired with %d axes of the second", len(axesA), len(axesB))}
+	}
+	if _, err := normalizeAxes(axesA, a.Ndim, "Tensordot"); err != nil {
+		return nil, err
+	}
+	if _, err := normalizeAxes(axesB, b.Ndim, "Tensordot"); err != nil {
+		return nil, err
+	}
+
+	// contracted axes in the order they are paired
+	contractA := make([]int, len(axesA))
+	contractB := make([]int, len(axesB))
+	inA := make([]bool, a.Ndim)
+	inB := make([]bool, b.Ndim)
+	k := 1
+	for i := range axesA {
+		contractA[i] = (axesA[i] + a.Ndim) % a.Ndim
+		contractB[i] = (axesB[i] + b.Ndim) % b.Ndim
+		inA[contractA[i]], inB[contractB[i]] = true, true
+
+		n := a.Shape[contractA[i]]
+		if n != b.Shape[contractB[i]] {
+			return nil, &ShapeError{Op: "Tensordot", Shape: b.Shape, Msg: fmt.Sprintf("axis %d must match size %d of axis %d of first array", contractB[i], n, contractA[i])}
+		}
+		k *= n
+	}
+
+	var freeA, freeB, shape []int
+	for ax := 0; ax < a.Ndim; ax++ {
+		if !inA[ax] {
+			freeA = append(freeA, ax)
+			shape = append(shape, a.Shape[ax])
+		}
+	}
+	for ax := 0; ax < b.Ndim; ax++ {
+		if !inB[ax] {
+			freeB = append(freeB, ax)
+			shape = append(shape, b.Shape[ax])
+		}
+	}
+
+	// (free axes of a, contracted) @ (contracted, free axes of b)
+	m, n := ShapeSize(shape[:len(freeA)]), ShapeSize(shape[len(freeA):])
+	a2 := a.Transpose(append(append([]int{}, freeA...), contractA...)).Reshape([]int{m, k})
+	b2 := b.Transpose(append(append([]int{}, contractB...), freeB...)).Reshape([]int{k, n})
+
+	if len(shape) == 0 {
+		shape = append(shape, 1)
+	}
+	return Matmul(a2, b2).Reshape(shape), nil
+}
+
+// Tensordot is like TryTensordot but panics on error
+func Tensordot[T Numeric](a, b *Array[T], axesA, axesB []int) *Array[T] {
+	return must(TryTensordot(a, b, axesA, axesB))
+}
+
+/*
+Kron is the Kronecker product of a and b, like numpy.kron. The array
+with fewer dimensions is padded with leading unit dimensions, and the
+result has shape a.Shape[i] * b.Shape[i] along every axis i, made of
+blocks a[i, j, ...] * b.
+*/
+func Kron[T Numeric](a, b *Array[T]) *Array[T] {
+	ndim := max(a.Ndim, b.Ndim)
+	padded := func(arr *Array[T]) []int {
+		shape := make([]int, ndim)
+		for i := range shape {
+			shape[i] = 1
+		}
+		copy(shape[ndim-arr.Ndim:], arr.Shape)
+		return shape
+	}
+	shapeA, shapeB := padded(a), padded(b)
+
+	// outer product of shape (a..., b...) with the axes of a and b
+	// interleaved, so that merging each pair gives the blocks
+	outer := Outer(a, b).Reshape(append(append([]int{}, shapeA...), shapeB...))
+	perm := make([]int, 0, 2*ndim)
+	shape := make([]int, ndim)
+	for i := 0; i < ndim; i++ {
+		perm = append(perm, i, ndim+i)
+		shape[i] = shapeA[i] * shapeB[i]
+	}
+	return outer.Transpose(perm).Reshape(shape)
+}
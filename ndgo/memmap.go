@@ -0,0 +1,214 @@
+package ndgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unsafe"
+)
+
+// Arrays backed by memory mapped files
+// ----------------------------------------------------------------
+
+// MemmapMode is the access mode of OpenMemmap, named after the modes
+// of numpy.memmap
+type MemmapMode int
+
+const (
+	// "r", the array must not be written to, writes crash the program
+	MemmapReadOnly MemmapMode = iota
+	// "c", writes change the array in memory but not the file
+	MemmapCopyOnWrite
+	// "r+", writes change the file
+	MemmapReadWrite
+	// "w+", like MemmapReadWrite but the file is created or
+	// overwritten with zeros first
+	MemmapCreate
+)
+
+/*
+Memmap is an Array whose Data aliases a memory mapped file, so arrays
+larger than the memory can be used: the operating system reads the
+pages of the file when they are accessed. Views of the Array share the
+mapping and must not be used after Close.
+*/
+type Memmap[T Elem] struct {
+	*Array[T]
+	mapping []byte
+	mode    MemmapMode
+}
+
+/*
+OpenMemmap maps the file at path as an Array of elements of type T.
+
+An NPY file, recognised by its magic string, gives the array it holds;
+its dtype must be T in the byte order of the host, and shape may be nil
+or must match the shape of the file, and its header must end at a
+multiple of the size of T, as numpy pads it. Any other file holds the raw
+elements of an array of the given shape in row major order and host
+byte order, the file may be larger than the array.
+
+With MemmapCreate, the file is created with the given shape, as an NPY
+file when path ends with ".npy" and as a raw file otherwise.
+*/
+func OpenMemmap[T Elem](path string, shape []int, mode MemmapMode) (*Memmap[T], error) {
+	flag := os.O_RDONLY
+	switch mode {
+	case MemmapReadOnly, MemmapCopyOnWrite:
+	case MemmapReadWrite:
+		flag = os.O_RDWR
+	case MemmapCreate:
+		flag = os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	default:
+		return nil, &ValueError{Op: "OpenMemmap", Msg: fmt.Sprintf("invalid mode %d", mode)}
+	}
+
+	f, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// the mapping stays valid once the file is closed
+	defer f.Close()
+
+	var offset int
+	var fortran bool
+	if mode == MemmapCreate {
+		offset, err = createMemmapFile[T](f, path, shape)
+	} else {
+		shape, offset, fortran, err = readMemmapHeader[T](f, shape)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	size := ShapeSize(shape)
+	var mapping []byte
+	var data []T
+	if size > 0 {
+		if mapping, err = mmap(f, offset+size*sizeof[T](), mode); err != nil {
+			return nil, err
+		}
+		data = unsafe.Slice((*T)(unsafe.Pointer(&mapping[offset])), size)
+	}
+
+	arr, err := TryWrap(data, shape)
+	if err != nil {
+		if mapping != nil {
+			munmap(mapping)
+		}
+		return nil, err
+	}
+	if fortran {
+		arr = arr.Transpose(nil)
+	}
+	return &Memmap[T]{Array: arr, mapping: mapping, mode: mode}, nil
+}
+
+// checks shape and writes an empty file for it, with an NPY header when
+// path ends with ".npy", returning the offset of the data
+func createMemmapFile[T Elem](f *os.File, path string, shape []int) (int, error) {
+	if len(shape) == 0 {
+		return 0, &ShapeError{Op: "OpenMemmap", Shape: shape, Msg: "a shape is needed to create a file"}
+	}
+	for _, v := range shape {
+		if v < 0 {
+			return 0, &ShapeError{Op: "OpenMemmap", Shape: shape, Msg: "negative dimensions are not allowed"}
+		}
+	}
+
+	var header []byte
+	if strings.HasSuffix(path, ".npy") {
+		header = npyHeader(npyDescr[T](), false, shape)
+		if _, err := f.Write(header); err != nil {
+			return 0, err
+		}
+	}
+	return len(header), f.Truncate(int64(len(header) + ShapeSize(shape)*sizeof[T]()))
+}
+
+/*
+readMemmapHeader reads the NPY header of f, if it has one, and returns
+the shape of the array stored in f, the offset of its data and whether
+it is stored in column major order, in which case the shape is reversed.
+*/
+func readMemmapHeader[T Elem](f *os.File, shape []int) ([]int, int, bool, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	magic := make([]byte, len(npyMagic))
+	n, _ := f.ReadAt(magic, 0)
+
+	offset, fortran := 0, false
+	if n == len(npyMagic) && string(magic) == npyMagic {
+		format, err := readNpyHeader(f)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		pos, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, 0, false, err
+		}
+
+		descr := npyDescr[T]()
+		native := format.size == 1 || format.order.Uint16([]byte{1, 0}) == binary.NativeEndian.Uint16([]byte{1, 0})
+		if format.kind != descr[1] || format.size != sizeof[T]() || !native {
+			return nil, 0, false, &ValueError{Op: "OpenMemmap", Msg: fmt.Sprintf("%s holds elements of kind %q and size %d in another byte order or type than %T", f.Name(), format.kind, format.size, *new(T))}
+		}
+		// the mapping starts at a page boundary, the elements are only
+		// aligned for T when the header length is a multiple of its size
+		if int(pos)%sizeof[T]() != 0 {
+			return nil, 0, false, &ValueError{Op: "OpenMemmap", Msg: fmt.Sprintf("the data of %s starts at byte %d, which is not aligned for %T", f.Name(), pos, *new(T))}
+		}
+		if shape != nil && !CheckShapesEqual(shape, format.shape) {
+			return nil, 0, false, &ShapeError{Op: "OpenMemmap", Shape: shape, Msg: fmt.Sprintf("%s holds an array of shape %v", f.Name(), format.shape)}
+		}
+
+		shape, offset, fortran = format.shape, int(pos), format.fortran
+		if fortran {
+			shape = make([]int, len(format.shape))
+			for i, v := range format.shape {
+				shape[len(shape)-1-i] = v
+			}
+		}
+	} else if shape == nil {
+		return nil, 0, false, &ShapeError{Op: "OpenMemmap", Shape: shape, Msg: fmt.Sprintf("a shape is needed to map the raw file %s", f.Name())}
+	}
+
+	for _, v := range shape {
+		if v < 0 {
+			return nil, 0, false, &ShapeError{Op: "OpenMemmap", Shape: shape, Msg: "negative dimensions are not allowed"}
+		}
+	}
+	if need := int64(offset + ShapeSize(shape)*sizeof[T]()); stat.Size() < need {
+		return nil, 0, false, &ShapeError{Op: "OpenMemmap", Shape: shape, Msg: fmt.Sprintf("%s holds %d bytes, %d are needed", f.Name(), stat.Size(), need)}
+	}
+	return shape, offset, fortran, nil
+}
+
+// Flush writes the changes of a MemmapReadWrite or MemmapCreate array
+// to its file, it does nothing in the other modes
+func (m *Memmap[T]) Flush() error {
+	if m.mapping == nil || (m.mode != MemmapReadWrite && m.mode != MemmapCreate) {
+		return nil
+	}
+	return msync(m.mapping)
+}
+
+/*
+Close flushes and unmaps the file. The Data of the Array is set to nil,
+any view of it still refers to the mapping and must not be used.
+*/
+func (m *Memmap[T]) Close() error {
+	if m.mapping == nil {
+		return nil
+	}
+	err := m.Flush()
+	if uerr := munmap(m.mapping); err == nil {
+		err = uerr
+	}
+	m.mapping, m.Data = nil, nil
+	return err
+}
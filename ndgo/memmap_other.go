@@ -0,0 +1,23 @@
+//go:build !(linux || darwin || freebsd || openbsd || dragonfly)
+
+package ndgo
+
+import (
+	"os"
+	"runtime"
+)
+
+// memory mapped files are only supported on the unix systems whose
+// syscall package provides Mmap and msync
+
+func mmap(f *os.File, length int, mode MemmapMode) ([]byte, error) {
+	return nil, &ValueError{Op: "OpenMemmap", Msg: "memory mapped files are not supported on " + runtime.GOOS}
+}
+
+func msync(b []byte) error {
+	return nil
+}
+
+func munmap(b []byte) error {
+	return nil
+}
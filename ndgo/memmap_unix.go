@@ -0,0 +1,34 @@
+//go:build linux || darwin || freebsd || openbsd || dragonfly
+
+package ndgo
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// maps the first length bytes of f with the protection of mode
+func mmap(f *os.File, length int, mode MemmapMode) ([]byte, error) {
+	prot, flags := syscall.PROT_READ, syscall.MAP_SHARED
+	switch mode {
+	case MemmapCopyOnWrite:
+		prot, flags = prot|syscall.PROT_WRITE, syscall.MAP_PRIVATE
+	case MemmapReadWrite, MemmapCreate:
+		prot |= syscall.PROT_WRITE
+	}
+	return syscall.Mmap(int(f.Fd()), 0, length, prot, flags)
+}
+
+// writes the changes of a shared mapping to its file
+func msync(b []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func munmap(b []byte) error {
+	return syscall.Munmap(b)
+}
@@ -0,0 +1,519 @@
+package ndgo
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Reading and writing the NPY format of numpy
+// ----------------------------------------------------------------
+
+const npyMagic = "\x93NUMPY"
+
+// elements encoded or decoded at a time, to bound the size of buffers
+const npyChunk = 1 << 14
+
+var (
+	npyDescrRe   = regexp.MustCompile(`['"]descr['"]\s*:\s*['"]([^'"]*)['"]`)
+	npyFortranRe = regexp.MustCompile(`['"]fortran_order['"]\s*:\s*(True|False)`)
+	npyShapeRe   = regexp.MustCompile(`['"]shape['"]\s*:\s*\(([^)]*)\)`)
+)
+
+// descr of the element type T, always little endian
+func npyDescr[T Elem]() string {
+	var zero T
+	var kind byte
+	switch any(zero).(type) {
+	case bool:
+		kind = 'b'
+	case float32, float64:
+		kind = 'f'
+	case uint, uint8, uint16, uint32, uint64:
+		kind = 'u'
+	default:
+		kind = 'i'
+	}
+
+	order := byte('<')
+	if sizeof[T]() == 1 {
+		order = '|'
+	}
+	return fmt.Sprintf("%c%c%d", order, kind, sizeof[T]())
+}
+
+// writes the little endian bytes of the integers vs to buf
+func putInts[T Integer](buf []byte, vs []T) {
+	size := len(buf) / max(len(vs), 1)
+	le := binary.LittleEndian
+	for i, v := range vs {
+		switch size {
+		case 1:
+			buf[i] = byte(v)
+		case 2:
+			le.PutUint16(buf[2*i:], uint16(v))
+		case 4:
+			le.PutUint32(buf[4*i:], uint32(v))
+		default:
+			le.PutUint64(buf[8*i:], uint64(v))
+		}
+	}
+}
+
+// writes the little endian bytes of values to buf
+func encodeNpy[T Elem](buf []byte, values []T) {
+	le := binary.LittleEndian
+	switch vs := any(values).(type) {
+	case []bool:
+		for i, v := range vs {
+			buf[i] = 0
+			if v {
+				buf[i] = 1
+			}
+		}
+	case []float32:
+		for i, v := range vs {
+			le.PutUint32(buf[4*i:], math.Float32bits(v))
+		}
+	case []float64:
+		for i, v := range vs {
+			le.PutUint64(buf[8*i:], math.Float64bits(v))
+		}
+	case []int:
+		putInts(buf, vs)
+	case []int8:
+		putInts(buf, vs)
+	case []int16:
+		putInts(buf, vs)
+	case []int32:
+		putInts(buf, vs)
+	case []int64:
+		putInts(buf, vs)
+	case []uint:
+		putInts(buf, vs)
+	case []uint8:
+		putInts(buf, vs)
+	case []uint16:
+		putInts(buf, vs)
+	case []uint32:
+		putInts(buf, vs)
+	case []uint64:
+		putInts(buf, vs)
+	}
+}
+
+/*
+npyHeader returns the magic string, version and header of an NPY file.
+The header is padded with spaces so that the data starts at a multiple
+of 64 bytes, and version 1.0 is used unless the padded header is too
+long for it.
+*/
+func npyHeader(descr string, fortran bool, shape []int) []byte {
+	order := "False"
+	if fortran {
+		order = "True"
+	}
+	dims := make([]string, len(shape))
+	for i, v := range shape {
+		dims[i] = strconv.Itoa(v)
+	}
+	tuple := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		tuple += ","
+	}
+	dict := fmt.Sprintf("{'descr': '%s', 'fortran_order': %s, 'shape': (%s), }", descr, order, tuple)
+
+	// the header length counts the padded dict and the final newline,
+	// version 1.0 stores it in 2 bytes and version 2.0 in 4
+	major := byte(1)
+	padded := func(prefix int) int {
+		total := prefix + len(dict) + 1
+		return total + (64-total%64)%64 - prefix
+	}
+	length := padded(len(npyMagic) + 2 + 2)
+	if length > math.MaxUint16 {
+		major, length = 2, padded(len(npyMagic)+2+4)
+	}
+	dict += strings.Repeat(" ", length-len(dict)-1) + "\n"
+
+	var buf bytes.Buffer
+	buf.WriteString(npyMagic)
+	buf.WriteByte(major)
+	buf.WriteByte(0)
+	if major == 1 {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(dict)))
+	} else {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(dict)))
+	}
+	buf.WriteString(dict)
+	return buf.Bytes()
+}
+
+/*
+SaveNpy writes arr to w in the NPY format of numpy, which numpy.load
+reads back. The data is little endian. Arrays which are contiguous in
+column major order (F_ORDER) are written as they are with
+fortran_order set, other non-contiguous views are written in row major
+order.
+*/
+func SaveNpy[T Elem](w io.Writer, arr *Array[T]) error {
+	fortran := arr.F_ORDER && !arr.C_ORDER
+	shape := arr.Shape
+	if !arr.C_ORDER && !fortran {
+		arr = arr.Copy()
+	}
+
+	if _, err := w.Write(npyHeader(npyDescr[T](), fortran, shape)); err != nil {
+		return err
+	}
+
+	// contiguous data in memory order
+	start := arr.Offset / arr.Itemsize
+	data := arr.Data[start : start+arr.Totalsize]
+	buf := make([]byte, min(npyChunk, len(data))*arr.Itemsize)
+	for i := 0; i < len(data); i += npyChunk {
+		chunk := data[i:min(i+npyChunk, len(data))]
+		encodeNpy(buf[:len(chunk)*arr.Itemsize], chunk)
+		if _, err := w.Write(buf[:len(chunk)*arr.Itemsize]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// npyFormat is the layout of the data of an NPY file
+type npyFormat struct {
+	kind    byte // b, i, u or f
+	size    int
+	order   binary.ByteOrder
+	fortran bool
+	shape   []int
+}
+
+// reads the magic string, version and header of an NPY file
+func readNpyHeader(r io.Reader) (*npyFormat, error) {
+	prefix := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, err
+	}
+	if string(prefix[:len(npyMagic)]) != npyMagic {
+		return nil, &ValueError{Op: "LoadNpy", Msg: "not an NPY file"}
+	}
+
+	var length int
+	switch major := prefix[len(npyMagic)]; major {
+	case 1:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		length = int(n)
+	case 2, 3:
+		// version 3 only differs by an utf8 header
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		length = int(n)
+	default:
+		return nil, &ValueError{Op: "LoadNpy", Msg: fmt.Sprintf("unsupported NPY version %d", major)}
+	}
+
+	header := make([]byte, length)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	descr := npyDescrRe.FindSubmatch(header)
+	fortran := npyFortranRe.FindSubmatch(header)
+	shape := npyShapeRe.FindSubmatch(header)
+	if descr == nil || fortran == nil || shape == nil {
+		return nil, &ValueError{Op: "LoadNpy", Msg: fmt.Sprintf("invalid or unsupported header %q", strings.TrimSpace(string(header)))}
+	}
+
+	format := &npyFormat{fortran: string(fortran[1]) == "True"}
+	for _, dim := range strings.Split(string(shape[1]), ",") {
+		if dim = strings.TrimSpace(dim); dim == "" {
+			continue
+		}
+		v, err := strconv.Atoi(dim)
+		if err != nil || v < 0 {
+			return nil, &ValueError{Op: "LoadNpy", Msg: fmt.Sprintf("invalid dimension %q in shape", dim)}
+		}
+		format.shape = append(format.shape, v)
+	}
+	// a numpy scalar
+	if len(format.shape) == 0 {
+		format.shape = []int{1}
+	}
+
+	d := string(descr[1])
+	if len(d) < 3 {
+		return nil, &ValueError{Op: "LoadNpy", Msg: fmt.Sprintf("unsupported dtype %q", d)}
+	}
+	format.order = binary.LittleEndian
+	if d[0] == '>' {
+		format.order = binary.BigEndian
+	}
+	format.kind = d[1]
+	size, err := strconv.Atoi(d[2:])
+	format.size = size
+
+	valid := map[byte][]int{'b': {1}, 'i': {1, 2, 4, 8}, 'u': {1, 2, 4, 8}, 'f': {4, 8}}
+	supported := false
+	for _, s := range valid[format.kind] {
+		supported = supported || s == size
+	}
+	if err != nil || !strings.ContainsRune("<>|=", rune(d[0])) || !supported {
+		return nil, &ValueError{Op: "LoadNpy", Msg: fmt.Sprintf("unsupported dtype %q", d)}
+	}
+
+	// the size of the data must fit in an int
+	count := 1
+	for _, v := range format.shape {
+		if v > 0 && count > math.MaxInt/size/v {
+			return nil, &ValueError{Op: "LoadNpy", Msg: fmt.Sprintf("shape %v is too large", format.shape)}
+		}
+		count *= v
+	}
+	return format, nil
+}
+
+// decodes the elements in buf to out, converting them to T
+func decodeNpy[T Elem](buf []byte, format *npyFormat, out []T) {
+	switch vs := any(out).(type) {
+	case []bool:
+		// the kind was checked to be 'b' by LoadNpy
+		for i := range vs {
+			vs[i] = buf[i] != 0
+		}
+	case []float32:
+		decodeNumbers(buf, format, vs)
+	case []float64:
+		decodeNumbers(buf, format, vs)
+	case []int:
+		decodeNumbers(buf, format, vs)
+	case []int8:
+		decodeNumbers(buf, format, vs)
+	case []int16:
+		decodeNumbers(buf, format, vs)
+	case []int32:
+		decodeNumbers(buf, format, vs)
+	case []int64:
+		decodeNumbers(buf, format, vs)
+	case []uint:
+		decodeNumbers(buf, format, vs)
+	case []uint8:
+		decodeNumbers(buf, format, vs)
+	case []uint16:
+		decodeNumbers(buf, format, vs)
+	case []uint32:
+		decodeNumbers(buf, format, vs)
+	case []uint64:
+		decodeNumbers(buf, format, vs)
+	}
+}
+
+// decodes the elements in buf to the numbers out, converting them to T
+func decodeNumbers[T Numeric](buf []byte, format *npyFormat, out []T) {
+	order, size := format.order, format.size
+	for i := range out {
+		b := buf[i*size : (i+1)*size]
+		switch {
+		case format.kind == 'f' && size == 4:
+			out[i] = T(math.Float32frombits(order.Uint32(b)))
+		case format.kind == 'f':
+			out[i] = T(math.Float64frombits(order.Uint64(b)))
+		case format.kind == 'b':
+			out[i] = 0
+			if b[0] != 0 {
+				out[i] = 1
+			}
+		case format.kind == 'u' && size == 1:
+			out[i] = T(b[0])
+		case format.kind == 'u' && size == 2:
+			out[i] = T(order.Uint16(b))
+		case format.kind == 'u' && size == 4:
+			out[i] = T(order.Uint32(b))
+		case format.kind == 'u':
+			out[i] = T(order.Uint64(b))
+		case size == 1:
+			out[i] = T(int8(b[0]))
+		case size == 2:
+			out[i] = T(int16(order.Uint16(b)))
+		case size == 4:
+			out[i] = T(int32(order.Uint32(b)))
+		default:
+			out[i] = T(int64(order.Uint64(b)))
+		}
+	}
+}
+
+/*
+LoadNpy reads an array in the NPY format of numpy (versions 1, 2 and
+3) from r, converting its elements to T like AsType. Both little and
+big endian data are read, booleans give 0 and 1 for numeric T, and
+boolean arrays can only be loaded from boolean data. Data stored in
+column major order (fortran_order) gives an F_ORDER view, and a
+0-dimensional array gives an array of shape [1].
+*/
+func LoadNpy[T Elem](r io.Reader) (*Array[T], error) {
+	format, err := readNpyHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := any(*new(T)).(bool); ok && format.kind != 'b' {
+		return nil, &ValueError{Op: "LoadNpy", Msg: fmt.Sprintf("elements of kind %q cannot be loaded as bool", format.kind)}
+	}
+
+	// column major data is the row major data of the transpose
+	shape := format.shape
+	if format.fortran {
+		shape = make([]int, len(format.shape))
+		for i, v := range format.shape {
+			shape[len(shape)-1-i] = v
+		}
+	}
+
+	// the array grows as the data is read rather than being allocated
+	// from the header, so a header declaring a huge shape fails on the
+	// missing data instead of exhausting the memory
+	total := ShapeSize(shape)
+	data := make([]T, 0, min(total, npyChunk))
+	buf := make([]byte, min(npyChunk, total)*format.size)
+	for len(data) < total {
+		n := min(npyChunk, total-len(data))
+		if _, err := io.ReadFull(r, buf[:n*format.size]); err != nil {
+			return nil, err
+		}
+		data = slices.Grow(data, n)[:len(data)+n]
+		decodeNpy(buf, format, data[len(data)-n:])
+	}
+
+	arr, err := TryWrap(data, shape)
+	if err != nil {
+		return nil, err
+	}
+	if format.fortran {
+		return arr.Transpose(nil), nil
+	}
+	return arr, nil
+}
+
+// Archives of arrays in the NPZ format
+// ----------------------------------------------------------------
+
+// NpzWriter writes arrays to a zip archive in the NPZ format of
+// numpy.savez, which numpy.load reads back
+type NpzWriter struct {
+	zw       *zip.Writer
+	compress bool
+}
+
+// NewNpzWriter returns an NpzWriter writing to w, the arrays are
+// compressed like numpy.savez_compressed when compress is true
+func NewNpzWriter(w io.Writer, compress bool) *NpzWriter {
+	return &NpzWriter{zw: zip.NewWriter(w), compress: compress}
+}
+
+// WriteNpz adds arr to the archive of nw under name, stored as name.npy
+func WriteNpz[T Elem](nw *NpzWriter, name string, arr *Array[T]) error {
+	method := zip.Store
+	if nw.compress {
+		method = zip.Deflate
+	}
+	f, err := nw.zw.CreateHeader(&zip.FileHeader{Name: name + ".npy", Method: method})
+	if err != nil {
+		return err
+	}
+	return SaveNpy(f, arr)
+}
+
+// Close finishes the archive, it does not close the underlying writer
+func (nw *NpzWriter) Close() error {
+	return nw.zw.Close()
+}
+
+// NpzReader reads arrays from an archive in the NPZ format of numpy
+type NpzReader struct {
+	files map[string]*zip.File
+}
+
+// OpenNpz opens the NPZ archive in r, which holds size bytes
+func OpenNpz(r io.ReaderAt, size int64) (*NpzReader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	nr := &NpzReader{files: map[string]*zip.File{}}
+	for _, f := range zr.File {
+		nr.files[strings.TrimSuffix(f.Name, ".npy")] = f
+	}
+	return nr, nil
+}
+
+// Names returns the names of the arrays in the archive, sorted
+func (nr *NpzReader) Names() []string {
+	names := make([]string, 0, len(nr.files))
+	for name := range nr.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadNpz reads the array stored under name in the archive of nr,
+// converting its elements to T like LoadNpy
+func ReadNpz[T Elem](nr *NpzReader, name string) (*Array[T], error) {
+	f, ok := nr.files[name]
+	if !ok {
+		return nil, &ValueError{Op: "ReadNpz", Msg: fmt.Sprintf("no array named %q in the archive", name)}
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return LoadNpy[T](rc)
+}
+
+// SaveNpz writes arrays to w as an uncompressed NPZ archive,
+// every array is stored under its key in the map
+func SaveNpz[T Elem](w io.Writer, arrays map[string]*Array[T]) error {
+	nw := NewNpzWriter(w, false)
+	names := make([]string, 0, len(arrays))
+	for name := range arrays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := WriteNpz(nw, name, arrays[name]); err != nil {
+			return err
+		}
+	}
+	return nw.Close()
+}
+
+// LoadNpz reads every array of the NPZ archive in r, which holds
+// size bytes, converting their elements to T like LoadNpy
+func LoadNpz[T Elem](r io.ReaderAt, size int64) (map[string]*Array[T], error) {
+	nr, err := OpenNpz(r, size)
+	if err != nil {
+		return nil, err
+	}
+	arrays := make(map[string]*Array[T], len(nr.files))
+	for _, name := range nr.Names() {
+		if arrays[name], err = ReadNpz[T](nr, name); err != nil {
+			return nil, err
+		}
+	}
+	return arrays, nil
+}
@@ -0,0 +1,300 @@
+package ndgo
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Formatting arrays as text
+// ----------------------------------------------------------------
+
+// FloatMode selects how Fprint formats floating point elements
+type FloatMode int
+
+const (
+	// scientific notation when the values span many orders of
+	// magnitude, like numpy, fixed point otherwise
+	FloatAuto FloatMode = iota
+	// always fixed point, like the suppress option of numpy
+	FloatFixed
+	// always scientific notation
+	FloatScientific
+)
+
+// PrintOptions configures Fprint, like numpy.set_printoptions
+type PrintOptions struct {
+	// maximum number of digits after the decimal point of floats,
+	// trailing zeros shared by every element are dropped
+	Precision int
+	// print exactly Precision digits, like the "fixed" floatmode of numpy
+	ExactPrecision bool
+	Mode           FloatMode
+	// arrays with more elements are summarised, showing EdgeItems
+	// elements at both ends of every axis around "..."
+	Threshold int
+	EdgeItems int
+	// lines of elements are wrapped to at most LineWidth characters
+	LineWidth int
+	// separator of the elements along the last axis
+	Separator string
+	// base of integer elements: 8, 10 or 16, 0 means 10
+	Base int
+}
+
+// DefaultPrintOptions returns the options used by String and PrettyPrint
+func DefaultPrintOptions() PrintOptions {
+	return PrintOptions{
+		Precision: 8,
+		Mode:      FloatAuto,
+		Threshold: 1000,
+		EdgeItems: 3,
+		LineWidth: 75,
+		Separator: " ",
+	}
+}
+
+// formats a float with the given number of digits, keeping the
+// decimal point of numbers without digits like numpy does
+func formatFloat(v float64, format byte, digits, bits int) string {
+	switch {
+	case math.IsNaN(v):
+		return "nan"
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(v, format, digits, bits)
+	if digits == 0 {
+		if i := strings.IndexByte(s, 'e'); i >= 0 {
+			return s[:i] + "." + s[i:]
+		}
+		return s + "."
+	}
+	return s
+}
+
+/*
+formatFloats formats the values with a common number of digits: the
+fewest that show every value to opts.Precision digits.
+*/
+func formatFloats(values []float64, bits int, opts PrintOptions) []string {
+	maxAbs, minAbs := 0.0, math.Inf(1)
+	for _, v := range values {
+		if a := math.Abs(v); a != 0 && !math.IsInf(a, 0) && !math.IsNaN(a) {
+			maxAbs, minAbs = math.Max(maxAbs, a), math.Min(minAbs, a)
+		}
+	}
+
+	format := byte('f')
+	switch opts.Mode {
+	case FloatScientific:
+		format = 'e'
+	case FloatAuto:
+		if maxAbs >= 1e8 || (maxAbs > 0 && (minAbs < 1e-4 || maxAbs/minAbs > 1e3)) {
+			format = 'e'
+		}
+	}
+
+	// digits needed by the value needing the most
+	digits := 0
+	precision := max(opts.Precision, 0)
+	if opts.ExactPrecision {
+		digits = precision
+	}
+	for _, v := range values {
+		if opts.ExactPrecision || math.IsInf(v, 0) || math.IsNaN(v) {
+			continue
+		}
+		s := strconv.FormatFloat(v, format, precision, bits)
+		mantissa, _, _ := strings.Cut(s, "e")
+		if _, frac, ok := strings.Cut(mantissa, "."); ok {
+			digits = max(digits, len(strings.TrimRight(frac, "0")))
+		}
+	}
+
+	res := make([]string, len(values))
+	for i, v := range values {
+		res[i] = formatFloat(v, format, digits, bits)
+		// like numpy, trailing zeros of fixed point numbers are
+		// blanked so that the decimal points stay aligned
+		if format == 'f' && !opts.ExactPrecision && strings.Contains(res[i], ".") {
+			trimmed := strings.TrimRight(res[i], "0")
+			res[i] = trimmed + strings.Repeat(" ", len(res[i])-len(trimmed))
+		}
+	}
+	return res
+}
+
+// formats the elements of type T
+func formatElems[T Elem](values []T, opts PrintOptions) []string {
+	switch vs := any(values).(type) {
+	case []float64:
+		return formatFloats(vs, 64, opts)
+	case []float32:
+		wide := make([]float64, len(vs))
+		for i, v := range vs {
+			wide[i] = float64(v)
+		}
+		return formatFloats(wide, 32, opts)
+	}
+	format := "%v"
+	switch opts.Base {
+	case 8:
+		format = "%o"
+	case 16:
+		format = "%x"
+	}
+	res := make([]string, len(values))
+	for i, v := range values {
+		res[i] = fmt.Sprintf(format, v)
+	}
+	return res
+}
+
+// printer renders an array with its elements already formatted
+type printer struct {
+	shape   []int
+	axes    [][]int // indices shown along every axis, -1 for "..."
+	strs    []string
+	width   int
+	next    int
+	opts    PrintOptions
+	builder strings.Builder
+}
+
+// renders the next sub-array at the given depth
+func (p *printer) render(depth int) {
+	b := &p.builder
+	b.WriteByte('[')
+	last := depth == len(p.shape)-1
+
+	if last {
+		// elements of the last axis, wrapped to the line width
+		col := depth + 1
+		for i, idx := range p.axes[depth] {
+			s := "..."
+			if idx >= 0 {
+				s = p.strs[p.next]
+				s = strings.Repeat(" ", max(p.width-len(s), 0)) + s
+				p.next++
+			}
+
+			if i > 0 {
+				if p.opts.LineWidth > 0 && col+len(p.opts.Separator)+len(s)+1 > p.opts.LineWidth {
+					b.WriteString(strings.TrimRight(p.opts.Separator, " "))
+					b.WriteString("\n" + strings.Repeat(" ", depth+1))
+					col = depth + 1
+				} else {
+					b.WriteString(p.opts.Separator)
+					col += len(p.opts.Separator)
+				}
+			}
+			b.WriteString(s)
+			col += len(s)
+		}
+		b.WriteByte(']')
+		return
+	}
+
+	sep := strings.Repeat("\n", len(p.shape)-depth-1) + strings.Repeat(" ", depth+1)
+	for i, idx := range p.axes[depth] {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		if idx < 0 {
+			b.WriteString("...")
+			continue
+		}
+		p.render(depth + 1)
+	}
+	b.WriteByte(']')
+}
+
+/*
+Fprint writes arr to w formatted like numpy prints arrays: nested
+brackets, elements aligned to a common width and lines wrapped to
+opts.LineWidth. Arrays with more than opts.Threshold elements are
+summarised with "..." in place of their middle elements.
+*/
+func Fprint[T Elem](w io.Writer, arr *Array[T], opts PrintOptions) error {
+	p := &printer{shape: arr.Shape, opts: opts}
+
+	summarise := opts.Threshold >= 0 && arr.Totalsize > opts.Threshold
+	edge := max(opts.EdgeItems, 1)
+	p.axes = make([][]int, arr.Ndim)
+	for d, n := range arr.Shape {
+		for i := 0; i < n; i++ {
+			if summarise && n > 2*edge && i == edge {
+				p.axes[d] = append(p.axes[d], -1)
+				i = n - edge
+			}
+			p.axes[d] = append(p.axes[d], i)
+		}
+	}
+
+	// the shown elements in the order they are printed
+	var values []T
+	var collect func(d, offset int)
+	collect = func(d, offset int) {
+		if d == arr.Ndim {
+			values = append(values, arr.Data[offset/arr.Itemsize])
+			return
+		}
+		for _, idx := range p.axes[d] {
+			if idx >= 0 {
+				collect(d+1, offset+idx*arr.Strides[d])
+			}
+		}
+	}
+	collect(0, arr.Offset)
+
+	p.strs = formatElems(values, opts)
+	for _, s := range p.strs {
+		p.width = max(p.width, len(s))
+	}
+
+	p.render(0)
+	_, err := io.WriteString(w, p.builder.String())
+	return err
+}
+
+// String formats arr with the DefaultPrintOptions
+func (arr *Array[T]) String() string {
+	var b strings.Builder
+	Fprint(&b, arr, DefaultPrintOptions())
+	return b.String()
+}
+
+/*
+Format implements fmt.Formatter. %v and %s use the DefaultPrintOptions,
+%f and %e force fixed point and scientific notation, %g chooses like
+%v, and a precision such as %.3f prints exactly that many digits.
+Arrays of integers also accept %d, %x and %o.
+*/
+func (arr *Array[T]) Format(f fmt.State, verb rune) {
+	opts := DefaultPrintOptions()
+	switch verb {
+	case 'v', 's', 'g':
+	case 'f':
+		opts.Mode = FloatFixed
+	case 'e':
+		opts.Mode = FloatScientific
+	case 'd', 'x', 'o':
+		if isInteger[T]() {
+			opts.Base = map[rune]int{'d': 10, 'x': 16, 'o': 8}[verb]
+			break
+		}
+		fallthrough
+	default:
+		fmt.Fprintf(f, "%%!%c(*ndgo.Array)", verb)
+		return
+	}
+	if precision, ok := f.Precision(); ok {
+		opts.Precision, opts.ExactPrecision = precision, true
+	}
+	Fprint(f, arr, opts)
+}
@@ -0,0 +1,341 @@
+package ndgo
+
+import (
+	"math"
+	"runtime"
+)
+
+// Reductions
+// ----------------------------------------------------------------
+
+/*
+reduceLayout prepares arr to be reduced over axes. It returns a view
+of arr with the kept axes first and the reduced axes last, so that
+every output element owns a run of `lane` consecutive positions of
+the view, along with the shape of the result.
+*/
+func reduceLayout[T Elem](arr *Array[T], axes []int, keepdims bool, op string) (*Array[T], []int, int, error) {
+	reduced, err := normalizeAxes(axes, arr.Ndim, op)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	isReduced := make([]bool, arr.Ndim)
+	for _, ax := range reduced {
+		isReduced[ax] = true
+	}
+
+	perm := make([]int, 0, arr.Ndim)
+	out_shape := make([]int, 0, arr.Ndim)
+	for ax := 0; ax < arr.Ndim; ax++ {
+		if !isReduced[ax] {
+			perm = append(perm, ax)
+			out_shape = append(out_shape, arr.Shape[ax])
+		} else if keepdims {
+			out_shape = append(out_shape, 1)
+		}
+	}
+
+	lane := 1
+	for _, ax := range reduced {
+		perm = append(perm, ax)
+		lane *= arr.Shape[ax]
+	}
+
+	// arrays have at least one dimension
+	if len(out_shape) == 0 {
+		out_shape = append(out_shape, 1)
+	}
+
+	return arr.Transpose(perm), out_shape, lane, nil
+}
+
+/*
+reduce is the driver shared by all reductions, op names the reduction
+in errors. Reductions without an identity (like Min) must set
+hasIdentity to false, they fail on empty lanes. laneFn folds n elements
+of a lane into an accumulator, reading them through an iterator that
+has been positioned at position `start` of the lane. Partial results of
+one lane are combined with merge, and final turns the accumulator of
+a complete lane of length n into the output value.
+
+Large inputs are reduced concurrently, either by splitting the output
+elements between goroutines, or, when there are only a few long lanes,
+by splitting every lane and merging the partial results in order.
+*/
+func reduce[T, R Elem, A any](
+	arr *Array[T], axes []int, keepdims bool, op string, hasIdentity bool,
+	laneFn func(data []T, it *NdIter, start, n int) A,
+	merge func(a, b A) A,
+	final func(acc A, n int) R,
+) (*Array[R], error) {
+	v, out_shape, lane, err := reduceLayout(arr, axes, keepdims, op)
+	if err != nil {
+		return nil, err
+	}
+	res := NewArrayFromShape[R](out_shape)
+	nout := res.Totalsize
+
+	if lane == 0 && nout > 0 && !hasIdentity {
+		return nil, &ValueError{Op: op, Msg: "zero-size reduction has no identity"}
+	}
+
+	reduceRange := func(s, e int) {
+		it := v.Iter()
+		for o := s; o < e; o++ {
+			it.Seek(o * lane)
+			res.Data[o] = final(laneFn(v.Data, it, 0, lane), lane)
+		}
+	}
+
+	if arr.Totalsize < PARALLEL_BOUNDARY {
+		reduceRange(0, nout)
+		return res, nil
+	}
+
+	n_routines := runtime.GOMAXPROCS(0)
+	if nout >= n_routines {
+		parallelFor(nout, reduceRange)
+		return res, nil
+	}
+
+	// few long lanes, split each lane into chunks
+	chunk_size := (lane + n_routines - 1) / n_routines
+	n_chunks := (lane + chunk_size - 1) / chunk_size
+	for o := 0; o < nout; o++ {
+		partials := make([]A, n_chunks)
+		parallelFor(n_chunks, func(s, e int) {
+			it := v.Iter()
+			for c := s; c < e; c++ {
+				start := c * chunk_size
+				n := chunk_size
+				if start+n > lane {
+					n = lane - start
+				}
+				it.Seek(o*lane + start)
+				partials[c] = laneFn(v.Data, it, start, n)
+			}
+		})
+
+		acc := partials[0]
+		for _, p := range partials[1:] {
+			acc = merge(acc, p)
+		}
+		res.Data[o] = final(acc, lane)
+	}
+	return res, nil
+}
+
+// kahan is a compensated float64 accumulator
+type kahan struct {
+	sum float64
+	c   float64
+}
+
+func (k *kahan) add(x float64) {
+	y := x - k.c
+	t := k.sum + y
+	k.c = (t - k.sum) - y
+	k.sum = t
+}
+
+func (k kahan) value() float64 {
+	return k.sum - k.c
+}
+
+func mergeKahan(a, b kahan) kahan {
+	a.add(b.sum)
+	a.add(-b.c)
+	return a
+}
+
+// sums a lane using Kahan summation in float64
+func kahanLane[T Numeric](data []T, it *NdIter, start, n int) kahan {
+	var k kahan
+	for i := 0; i < n; i++ {
+		it.Next()
+		k.add(float64(data[it.Index()]))
+	}
+	return k
+}
+
+// sums a lane of integers exactly in T
+func intSumLane[T Numeric](data []T, it *NdIter, start, n int) T {
+	var s T
+	for i := 0; i < n; i++ {
+		it.Next()
+		s += data[it.Index()]
+	}
+	return s
+}
+
+// extremum holds the best value seen in a lane, and its position
+type extremum[T Numeric] struct {
+	value T
+	pos   int
+	valid bool
+}
+
+/*
+extremumFns returns the lane and merge functions for Min/Max style
+reductions, less reports whether x should replace the current best.
+NaN values always win, so that they propagate like in numpy.
+*/
+func extremumFns[T Numeric](less func(x, best T) bool) (
+	func([]T, *NdIter, int, int) extremum[T],
+	func(a, b extremum[T]) extremum[T],
+) {
+	better := func(x, best T) bool {
+		if best != best {
+			return false
+		}
+		return x != x || less(x, best)
+	}
+
+	laneFn := func(data []T, it *NdIter, start, n int) extremum[T] {
+		var e extremum[T]
+		for i := 0; i < n; i++ {
+			it.Next()
+			x := data[it.Index()]
+			if !e.valid || better(x, e.value) {
+				e = extremum[T]{value: x, pos: start + i, valid: true}
+			}
+		}
+		return e
+	}
+
+	merge := func(a, b extremum[T]) extremum[T] {
+		if !a.valid || (b.valid && better(b.value, a.value)) {
+			return b
+		}
+		return a
+	}
+
+	return laneFn, merge
+}
+
+func lessThan[T Numeric](x, y T) bool    { return x < y }
+func greaterThan[T Numeric](x, y T) bool { return x > y }
+
+/*
+TrySum is the sum of array elements over the given axes, all axes
+are reduced when axes is nil. If keepdims is true the reduced axes
+are left in the result with size one.
+
+Floats are accumulated in float64 using Kahan summation.
+*/
+func TrySum[T Numeric](arr *Array[T], axes []int, keepdims bool) (*Array[T], error) {
+	if isFloat[T]() {
+		return reduce(arr, axes, keepdims, "Sum", true, kahanLane[T], mergeKahan,
+			func(k kahan, n int) T { return T(k.value()) })
+	}
+	return reduce(arr, axes, keepdims, "Sum", true, intSumLane[T],
+		func(a, b T) T { return a + b },
+		func(s T, n int) T { return s })
+}
+
+// Sum is like TrySum but panics on error
+func Sum[T Numeric](arr *Array[T], axes []int, keepdims bool) *Array[T] {
+	return must(TrySum(arr, axes, keepdims))
+}
+
+/*
+TryMean is the mean of array elements over the given axes, computed
+in float64. For integer arrays the result is truncated toward zero to
+T, and the mean of an empty lane, NaN for floats, is a ValueError.
+*/
+func TryMean[T Numeric](arr *Array[T], axes []int, keepdims bool) (*Array[T], error) {
+	if !isFloat[T]() {
+		_, out_shape, lane, err := reduceLayout(arr, axes, keepdims, "Mean")
+		if err != nil {
+			return nil, err
+		}
+		if lane == 0 && ShapeSize(out_shape) > 0 {
+			return nil, &ValueError{Op: "Mean", Msg: "mean of an empty integer lane is not representable"}
+		}
+	}
+	return reduce(arr, axes, keepdims, "Mean", true, kahanLane[T], mergeKahan,
+		func(k kahan, n int) T {
+			if n == 0 {
+				return T(math.NaN())
+			}
+			return T(k.value() / float64(n))
+		})
+}
+
+// Mean is like TryMean but panics on error
+func Mean[T Numeric](arr *Array[T], axes []int, keepdims bool) *Array[T] {
+	return must(TryMean(arr, axes, keepdims))
+}
+
+// TryProd is the product of array elements over the given axes
+func TryProd[T Numeric](arr *Array[T], axes []int, keepdims bool) (*Array[T], error) {
+	return reduce(arr, axes, keepdims, "Prod", true,
+		func(data []T, it *NdIter, start, n int) T {
+			p := T(1)
+			for i := 0; i < n; i++ {
+				it.Next()
+				p *= data[it.Index()]
+			}
+			return p
+		},
+		func(a, b T) T { return a * b },
+		func(p T, n int) T { return p })
+}
+
+// Prod is like TryProd but panics on error
+func Prod[T Numeric](arr *Array[T], axes []int, keepdims bool) *Array[T] {
+	return must(TryProd(arr, axes, keepdims))
+}
+
+// TryMin is the minimum of array elements over the given axes
+func TryMin[T Numeric](arr *Array[T], axes []int, keepdims bool) (*Array[T], error) {
+	laneFn, merge := extremumFns(lessThan[T])
+	return reduce(arr, axes, keepdims, "Min", false, laneFn, merge,
+		func(e extremum[T], n int) T { return e.value })
+}
+
+// Min is like TryMin but panics on error
+func Min[T Numeric](arr *Array[T], axes []int, keepdims bool) *Array[T] {
+	return must(TryMin(arr, axes, keepdims))
+}
+
+// TryMax is the maximum of array elements over the given axes
+func TryMax[T Numeric](arr *Array[T], axes []int, keepdims bool) (*Array[T], error) {
+	laneFn, merge := extremumFns(greaterThan[T])
+	return reduce(arr, axes, keepdims, "Max", false, laneFn, merge,
+		func(e extremum[T], n int) T { return e.value })
+}
+
+// Max is like TryMax but panics on error
+func Max[T Numeric](arr *Array[T], axes []int, keepdims bool) *Array[T] {
+	return must(TryMax(arr, axes, keepdims))
+}
+
+/*
+TryArgMin returns the position of the first minimum over the given axes.
+Positions index the reduced axes as if they were flattened in row
+major order, so with nil axes they are indices into the flattened array.
+*/
+func TryArgMin[T Numeric](arr *Array[T], axes []int, keepdims bool) (*Array[int], error) {
+	laneFn, merge := extremumFns(lessThan[T])
+	return reduce(arr, axes, keepdims, "ArgMin", false, laneFn, merge,
+		func(e extremum[T], n int) int { return e.pos })
+}
+
+// ArgMin is like TryArgMin but panics on error
+func ArgMin[T Numeric](arr *Array[T], axes []int, keepdims bool) *Array[int] {
+	return must(TryArgMin(arr, axes, keepdims))
+}
+
+// TryArgMax returns the position of the first maximum over the given axes,
+// see TryArgMin for how positions are counted.
+func TryArgMax[T Numeric](arr *Array[T], axes []int, keepdims bool) (*Array[int], error) {
+	laneFn, merge := extremumFns(greaterThan[T])
+	return reduce(arr, axes, keepdims, "ArgMax", false, laneFn, merge,
+		func(e extremum[T], n int) int { return e.pos })
+}
+
+// ArgMax is like TryArgMax but panics on error
+func ArgMax[T Numeric](arr *Array[T], axes []int, keepdims bool) *Array[int] {
+	return must(TryArgMax(arr, axes, keepdims))
+}
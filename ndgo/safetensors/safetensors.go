@@ -0,0 +1,320 @@
+/*
+Package safetensors reads and writes ndgo arrays in the safetensors
+format of Hugging Face.
+
+A file is an 8 byte little endian header length, a JSON header mapping
+the name of every tensor to its dtype, shape and data offsets, and the
+data of the tensors as little endian bytes. Tensors whose dtype matches
+the element type of the requested Array share their memory with the
+file, other tensors are converted with TensorAs.
+*/
+package safetensors
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"unsafe"
+
+	ng "ndgo/ndgo"
+)
+
+// name of the header entry holding the metadata of the file
+const metadataKey = "__metadata__"
+
+// size in bytes of the elements of every dtype
+var dtypeSizes = map[string]int{
+	"BOOL": 1, "U8": 1, "I8": 1, "F8_E4M3": 1, "F8_E5M2": 1,
+	"U16": 2, "I16": 2, "F16": 2, "BF16": 2,
+	"U32": 4, "I32": 4, "F32": 4,
+	"U64": 8, "I64": 8, "F64": 8,
+}
+
+// whether the host stores numbers in little endian order, the order
+// of the data of a file
+var littleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// dtype of the elements of type T
+func dtypeOf[T ng.Elem]() string {
+	var zero T
+	size := int(unsafe.Sizeof(zero))
+	switch any(zero).(type) {
+	case bool:
+		return "BOOL"
+	case float32, float64:
+		return fmt.Sprintf("F%d", 8*size)
+	case uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("U%d", 8*size)
+	}
+	return fmt.Sprintf("I%d", 8*size)
+}
+
+// TensorInfo is the header entry of a tensor, DataOffsets are the
+// start and end of its bytes relative to the data of the file
+type TensorInfo struct {
+	DType       string `json:"dtype"`
+	Shape       []int  `json:"shape"`
+	DataOffsets [2]int `json:"data_offsets"`
+}
+
+// File is a parsed safetensors file
+type File struct {
+	Metadata map[string]string
+	tensors  map[string]TensorInfo
+	data     []byte
+}
+
+/*
+Parse parses the safetensors file in b, which must not be modified
+while the tensors of the File are used. A ValueError reports a
+malformed header, an unknown dtype, offsets which do not match the
+shape of a tensor, fall outside of the data or overlap another tensor.
+*/
+func Parse(b []byte) (*File, error) {
+	if len(b) < 8 {
+		return nil, &ng.ValueError{Op: "Parse", Msg: "file is too short for a safetensors header"}
+	}
+	n := binary.LittleEndian.Uint64(b)
+	if n > uint64(len(b)-8) {
+		return nil, &ng.ValueError{Op: "Parse", Msg: fmt.Sprintf("header length %d exceeds the file size %d", n, len(b))}
+	}
+
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(b[8:8+n], &entries); err != nil {
+		return nil, &ng.ValueError{Op: "Parse", Msg: fmt.Sprintf("invalid header: %v", err)}
+	}
+
+	f := &File{tensors: make(map[string]TensorInfo, len(entries)), data: b[8+n:]}
+	for name, raw := range entries {
+		if name == metadataKey {
+			if err := json.Unmarshal(raw, &f.Metadata); err != nil {
+				return nil, &ng.ValueError{Op: "Parse", Msg: fmt.Sprintf("invalid metadata: %v", err)}
+			}
+			continue
+		}
+		var info TensorInfo
+		if err := json.Unmarshal(raw, &info); err != nil {
+			return nil, &ng.ValueError{Op: "Parse", Msg: fmt.Sprintf("invalid entry of tensor %q: %v", name, err)}
+		}
+		if err := f.check(name, info); err != nil {
+			return nil, err
+		}
+		f.tensors[name] = info
+	}
+
+	// tensors sorted by their offsets only overlap their neighbours
+	names := f.Names()
+	sort.SliceStable(names, func(i, j int) bool {
+		a, b := f.tensors[names[i]].DataOffsets, f.tensors[names[j]].DataOffsets
+		return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+	})
+	for i := 1; i < len(names); i++ {
+		prev, curr := f.tensors[names[i-1]], f.tensors[names[i]]
+		if curr.DataOffsets[0] < prev.DataOffsets[1] {
+			return nil, &ng.ValueError{Op: "Parse", Msg: fmt.Sprintf("tensor %q at bytes %v overlaps tensor %q at bytes %v", names[i], curr.DataOffsets, names[i-1], prev.DataOffsets)}
+		}
+	}
+	return f, nil
+}
+
+// returns a ValueError unless the entry of a tensor is consistent
+func (f *File) check(name string, info TensorInfo) error {
+	size, ok := dtypeSizes[info.DType]
+	if !ok {
+		return &ng.ValueError{Op: "Parse", Msg: fmt.Sprintf("tensor %q has unknown dtype %q", name, info.DType)}
+	}
+
+	start, end := info.DataOffsets[0], info.DataOffsets[1]
+	if start < 0 || end < start || end > len(f.data) {
+		return &ng.ValueError{Op: "Parse", Msg: fmt.Sprintf("offsets %v of tensor %q are out of bounds of the %d bytes of data", info.DataOffsets, name, len(f.data))}
+	}
+
+	// the count saturates instead of overflowing, no tensor holds more
+	// elements than its offsets span bytes
+	count := 1
+	for _, v := range info.Shape {
+		if v < 0 {
+			return &ng.ValueError{Op: "Parse", Msg: fmt.Sprintf("tensor %q has negative dimensions %v", name, info.Shape)}
+		}
+		if v > 0 && count > (end-start)/v {
+			count = end - start + 1
+		} else {
+			count *= v
+		}
+	}
+	if count > end-start {
+		return &ng.ValueError{Op: "Parse", Msg: fmt.Sprintf("tensor %q of shape %v has more elements than its offsets %v span bytes", name, info.Shape, info.DataOffsets)}
+	}
+	if end-start != count*size {
+		return &ng.ValueError{Op: "Parse", Msg: fmt.Sprintf("tensor %q of dtype %s and shape %v needs %d bytes, its offsets %v span %d", name, info.DType, info.Shape, count*size, info.DataOffsets, end-start)}
+	}
+	return nil
+}
+
+// Load reads a safetensors file from r and parses it
+func Load(r io.Reader) (*File, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Names returns the names of the tensors in the file, sorted
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.tensors))
+	for name := range f.tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Info returns the header entry of the tensor called name
+func (f *File) Info(name string) (TensorInfo, bool) {
+	info, ok := f.tensors[name]
+	return info, ok
+}
+
+// the header entry of a tensor, and the shape of its Array, in which
+// a scalar has the shape [1] like in LoadNpy
+func (f *File) lookup(name, op string) (TensorInfo, []int, error) {
+	info, ok := f.tensors[name]
+	if !ok {
+		return info, nil, &ng.ValueError{Op: op, Msg: fmt.Sprintf("no tensor named %q in the file", name)}
+	}
+	shape := info.Shape
+	if len(shape) == 0 {
+		shape = []int{1}
+	}
+	return info, shape, nil
+}
+
+/*
+Tensor returns the tensor called name, whose dtype must match T. The
+Array shares its memory with the file when the host is little endian
+and the data is aligned for T, so writing to it modifies the file
+bytes; otherwise the data is copied.
+*/
+func Tensor[T ng.Elem](f *File, name string) (*ng.Array[T], error) {
+	info, shape, err := f.lookup(name, "Tensor")
+	if err != nil {
+		return nil, err
+	}
+	if dtype := dtypeOf[T](); info.DType != dtype {
+		return nil, &ng.ValueError{Op: "Tensor", Msg: fmt.Sprintf("tensor %q has dtype %s, not %s, convert it with TensorAs", name, info.DType, dtype)}
+	}
+
+	b := f.data[info.DataOffsets[0]:info.DataOffsets[1]]
+	if info.DType == "BOOL" {
+		for _, v := range b {
+			if v > 1 {
+				return nil, &ng.ValueError{Op: "Tensor", Msg: fmt.Sprintf("tensor %q holds the invalid boolean byte %d", name, v)}
+			}
+		}
+	}
+	size := int(unsafe.Sizeof(*new(T)))
+	var data []T
+	if len(b) > 0 && littleEndian && uintptr(unsafe.Pointer(&b[0]))%uintptr(size) == 0 {
+		data = unsafe.Slice((*T)(unsafe.Pointer(&b[0])), len(b)/size)
+	} else {
+		data = make([]T, len(b)/size)
+		copy(bytesOf(data), b)
+		if !littleEndian {
+			swapBytes(bytesOf(data), size)
+		}
+	}
+	return ng.TryWrap(data, shape)
+}
+
+/*
+TensorAs returns a copy of the tensor called name converted to T like
+AsType, whatever its dtype. Booleans give 0 and 1, and the half
+precision F16 and BF16 dtypes are widened exactly.
+*/
+func TensorAs[T ng.Numeric](f *File, name string) (*ng.Array[T], error) {
+	info, shape, err := f.lookup(name, "TensorAs")
+	if err != nil {
+		return nil, err
+	}
+	b := f.data[info.DataOffsets[0]:info.DataOffsets[1]]
+	le := binary.LittleEndian
+	size := dtypeSizes[info.DType]
+	data := make([]T, len(b)/size)
+
+	for i := range data {
+		e := b[i*size : (i+1)*size]
+		switch info.DType {
+		case "BOOL":
+			data[i] = 0
+			if e[0] != 0 {
+				data[i] = 1
+			}
+		case "U8":
+			data[i] = T(e[0])
+		case "I8":
+			data[i] = T(int8(e[0]))
+		case "U16":
+			data[i] = T(le.Uint16(e))
+		case "I16":
+			data[i] = T(int16(le.Uint16(e)))
+		case "F16":
+			data[i] = T(halfToFloat32(le.Uint16(e)))
+		case "BF16":
+			data[i] = T(math.Float32frombits(uint32(le.Uint16(e)) << 16))
+		case "U32":
+			data[i] = T(le.Uint32(e))
+		case "I32":
+			data[i] = T(int32(le.Uint32(e)))
+		case "F32":
+			data[i] = T(math.Float32frombits(le.Uint32(e)))
+		case "U64":
+			data[i] = T(le.Uint64(e))
+		case "I64":
+			data[i] = T(int64(le.Uint64(e)))
+		case "F64":
+			data[i] = T(math.Float64frombits(le.Uint64(e)))
+		default:
+			return nil, &ng.ValueError{Op: "TensorAs", Msg: fmt.Sprintf("tensor %q has unsupported dtype %s", name, info.DType)}
+		}
+	}
+	return ng.TryWrap(data, shape)
+}
+
+// converts the bits of an IEEE 754 half precision float to a float32
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h) & 0x3ff
+	switch exp {
+	case 0:
+		// zero and subnormals
+		v := float32(mant) * 0x1p-24
+		if sign != 0 {
+			v = -v
+		}
+		return v
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp+112)<<23 | mant<<13)
+}
+
+// the memory of the elements of data as bytes
+func bytesOf[T ng.Elem](data []T) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&data[0])), len(data)*int(unsafe.Sizeof(data[0])))
+}
+
+// reverses the bytes of every element of the given size in b
+func swapBytes(b []byte, size int) {
+	for i := 0; i < len(b); i += size {
+		slices.Reverse(b[i : i+size])
+	}
+}
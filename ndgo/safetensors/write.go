@@ -0,0 +1,116 @@
+package safetensors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	ng "ndgo/ndgo"
+)
+
+// a tensor added to a Writer
+type entry struct {
+	dtype string
+	shape []int
+	data  []byte
+}
+
+/*
+Writer collects named arrays of any element types and writes them as a
+safetensors file. The header must precede the data, so nothing is
+written before WriteTo.
+*/
+type Writer struct {
+	Metadata map[string]string
+	tensors  map[string]entry
+}
+
+// NewWriter returns a Writer of a file with the given metadata, which
+// may be nil
+func NewWriter(metadata map[string]string) *Writer {
+	return &Writer{Metadata: metadata, tensors: make(map[string]entry)}
+}
+
+/*
+Add adds arr to sw under name, views are stored in their logical order.
+The data of C-contiguous arrays is not copied on little endian hosts,
+so arr must not be modified until the file is written.
+*/
+func Add[T ng.Elem](sw *Writer, name string, arr *ng.Array[T]) error {
+	if name == metadataKey {
+		return &ng.ValueError{Op: "Add", Msg: fmt.Sprintf("%q is reserved for the metadata", name)}
+	}
+	if _, ok := sw.tensors[name]; ok {
+		return &ng.ValueError{Op: "Add", Msg: fmt.Sprintf("a tensor named %q was already added", name)}
+	}
+
+	if !arr.C_ORDER {
+		arr = arr.Copy()
+	}
+	start := arr.Offset / arr.Itemsize
+	data := bytesOf(arr.Data[start : start+arr.Totalsize])
+	if !littleEndian {
+		data = bytes.Clone(data)
+		swapBytes(data, arr.Itemsize)
+	}
+	sw.tensors[name] = entry{dtype: dtypeOf[T](), shape: append([]int{}, arr.Shape...), data: data}
+	return nil
+}
+
+// WriteTo writes the file to w, with the tensors stored in the order of
+// their names
+func (sw *Writer) WriteTo(w io.Writer) (int64, error) {
+	names := make([]string, 0, len(sw.tensors))
+	for name := range sw.tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	header := make(map[string]any, len(names)+1)
+	if len(sw.Metadata) > 0 {
+		header[metadataKey] = sw.Metadata
+	}
+	offset := 0
+	for _, name := range names {
+		e := sw.tensors[name]
+		header[name] = TensorInfo{DType: e.dtype, Shape: e.shape, DataOffsets: [2]int{offset, offset + len(e.data)}}
+		offset += len(e.data)
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return 0, err
+	}
+	// the data starts at a multiple of 8 bytes
+	h = append(h, strings.Repeat(" ", (8-len(h)%8)%8)...)
+
+	chunks := [][]byte{binary.LittleEndian.AppendUint64(nil, uint64(len(h))), h}
+	for _, name := range names {
+		chunks = append(chunks, sw.tensors[name].data)
+	}
+	var total int64
+	for _, b := range chunks {
+		n, err := w.Write(b)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Save writes the named arrays to w as a safetensors file with the
+// given metadata, which may be nil
+func Save[T ng.Elem](w io.Writer, tensors map[string]*ng.Array[T], metadata map[string]string) error {
+	sw := NewWriter(metadata)
+	for name, arr := range tensors {
+		if err := Add(sw, name, arr); err != nil {
+			return err
+		}
+	}
+	_, err := sw.WriteTo(w)
+	return err
+}
@@ -0,0 +1,116 @@
+package ndgo
+
+import (
+	"math"
+)
+
+// None marks an omitted Start or Stop of a Range
+const None = math.MinInt
+
+/*
+Range selects the indices start:stop:step along one axis of an Array,
+with the same semantics as a python slice: negative Start and Stop
+count from the end of the axis, a negative Step walks the axis
+backwards, and None leaves Start or Stop at its default.
+
+A Step of 0 is treated as 1.
+*/
+type Range struct {
+	Start int
+	Stop  int
+	Step  int
+}
+
+// FullRange selects every element along an axis
+func FullRange() Range {
+	return Range{Start: None, Stop: None, Step: 1}
+}
+
+// Span selects the elements start:stop along an axis
+func Span(start, stop int) Range {
+	return Range{Start: start, Stop: stop, Step: 1}
+}
+
+// resolve returns the first index, the number of selected
+// elements and the step of the range for an axis of length n
+func (r Range) resolve(n int) (start, length, step int) {
+	step = r.Step
+	if step == 0 {
+		step = 1
+	}
+
+	lower, upper := 0, n
+	if step < 0 {
+		lower, upper = -1, n-1
+	}
+
+	clamp := func(v, def int) int {
+		if v == None {
+			return def
+		}
+		if v < 0 {
+			v += n
+			if v < lower {
+				v = lower
+			}
+		} else if v > upper {
+			v = upper
+		}
+		return v
+	}
+
+	stop := 0
+	if step > 0 {
+		start, stop = clamp(r.Start, lower), clamp(r.Stop, upper)
+		if stop > start {
+			length = (stop - start + step - 1) / step
+		}
+	} else {
+		start, stop = clamp(r.Start, upper), clamp(r.Stop, lower)
+		if start > stop {
+			length = (start - stop - step - 1) / -step
+		}
+	}
+
+	return start, length, step
+}
+
+/*
+TrySlice returns a view of arr selecting the given range along each
+axis, axes without a range are selected entirely. The result shares
+data with arr, so writes through the view are visible in arr.
+
+	a := Arange[float32](0, 12, 1).Reshape([]int{3, 4})
+	b := a.Slice(Span(1, 3), Range{Start: None, Stop: None, Step: -2})
+	// b has shape [2 2] and holds a[1:3, ::-2]
+*/
+func (arr *Array[T]) TrySlice(ranges ...Range) (*Array[T], error) {
+	if len(ranges) > arr.Ndim {
+		return nil, &AxisError{Op: "Slice", Axis: arr.Ndim, Ndim: arr.Ndim, Msg: "is out of bounds"}
+	}
+
+	shape := make([]int, arr.Ndim)
+	strides := make([]int, arr.Ndim)
+	offset := arr.Offset
+
+	for i := 0; i < arr.Ndim; i++ {
+		r := FullRange()
+		if i < len(ranges) {
+			r = ranges[i]
+		}
+
+		start, length, step := r.resolve(arr.Shape[i])
+		shape[i] = length
+		strides[i] = arr.Strides[i] * step
+		if length > 0 {
+			offset += start * arr.Strides[i]
+		}
+	}
+
+	return arr.view(shape, strides, offset), nil
+}
+
+// Slice is like TrySlice but panics on error
+func (arr *Array[T]) Slice(ranges ...Range) *Array[T] {
+	return must(arr.TrySlice(ranges...))
+}
@@ -0,0 +1,213 @@
+package ndgo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Reading and writing delimited text
+// ----------------------------------------------------------------
+
+/*
+LoadTextOptions configures LoadText, the zero value reads comma
+separated values.
+*/
+type LoadTextOptions struct {
+	// separator of the fields of a line, ',' when zero; ' ' splits
+	// on any run of whitespace
+	Delimiter rune
+	// number of lines skipped at the start, e.g. a header
+	SkipRows int
+	// indices of the columns to read, negative indices count from the
+	// end; all columns when nil
+	Columns []int
+	// the rest of a line after this prefix is ignored, "#" when empty
+	Comments string
+	// fields which are missing, along with empty fields, e.g. "NA"
+	Missing []string
+	// value given to missing fields, truncated for integer arrays
+	Fill float64
+}
+
+// parses a field of text into a value of type T
+func parseText[T Numeric](field string) (T, error) {
+	if isFloat[T]() {
+		v, err := strconv.ParseFloat(field, sizeof[T]()*8)
+		return T(v), err
+	}
+	if isUnsigned[T]() {
+		v, err := strconv.ParseUint(field, 10, sizeof[T]()*8)
+		return T(v), err
+	}
+	v, err := strconv.ParseInt(field, 10, sizeof[T]()*8)
+	return T(v), err
+}
+
+// splits a line of text into its fields
+func splitText(line string, delimiter rune) []string {
+	if delimiter == ' ' {
+		return strings.Fields(line)
+	}
+	fields := strings.Split(line, string(delimiter))
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+	}
+	return fields
+}
+
+/*
+LoadText reads delimited text, such as CSV, from r into a 2D array of
+shape (rows, columns), like numpy.loadtxt. Blank lines and comments are
+skipped, every remaining line is a row and must have the same number of
+fields. Fields are parsed as T, and empty or missing fields take the
+value opts.Fill. A ValueError reports the line of a field which cannot
+be parsed.
+*/
+func LoadText[T Numeric](r io.Reader, opts LoadTextOptions) (*Array[T], error) {
+	delimiter := opts.Delimiter
+	if delimiter == 0 {
+		delimiter = ','
+	}
+	comments := opts.Comments
+	if comments == "" {
+		comments = "#"
+	}
+	missing := map[string]bool{"": true}
+	for _, m := range opts.Missing {
+		missing[m] = true
+	}
+	fill := T(opts.Fill)
+
+	var values []T
+	ncols := -1
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if line <= opts.SkipRows {
+			continue
+		}
+		if i := strings.Index(text, comments); i >= 0 {
+			text = text[:i]
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		fields := splitText(text, delimiter)
+		if opts.Columns != nil {
+			selected := make([]string, len(opts.Columns))
+			for i, c := range opts.Columns {
+				if c < -len(fields) || c >= len(fields) {
+					return nil, &ValueError{Op: "LoadText", Msg: fmt.Sprintf("line %d has no column %d", line, c)}
+				}
+				if c < 0 {
+					c += len(fields)
+				}
+				selected[i] = fields[c]
+			}
+			fields = selected
+		}
+
+		if ncols == -1 {
+			ncols = len(fields)
+		} else if len(fields) != ncols {
+			return nil, &ValueError{Op: "LoadText", Msg: fmt.Sprintf("line %d has %d columns instead of %d", line, len(fields), ncols)}
+		}
+
+		for _, f := range fields {
+			if missing[f] {
+				values = append(values, fill)
+				continue
+			}
+			v, err := parseText[T](f)
+			if err != nil {
+				return nil, &ValueError{Op: "LoadText", Msg: fmt.Sprintf("cannot parse %q on line %d", f, line)}
+			}
+			values = append(values, v)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	ncols = max(ncols, 0)
+	rows := 0
+	if ncols > 0 {
+		rows = len(values) / ncols
+	}
+	arr := NewArrayFromShape[T]([]int{rows, ncols})
+	copy(arr.Data, values)
+	return arr, nil
+}
+
+/*
+SaveTextOptions configures SaveText, the zero value writes comma
+separated values.
+*/
+type SaveTextOptions struct {
+	// separator of the fields of a line, ',' when zero
+	Delimiter rune
+	// format verb of every element, "%v" when empty, e.g. "%.3f"
+	Format string
+	// lines written before and after the data, prefixed by Comments
+	Header string
+	Footer string
+	// prefix of the header and footer lines, "# " when empty
+	Comments string
+}
+
+/*
+SaveText writes a 1D or 2D array to w as delimited text, like
+numpy.savetxt. Every row of a 2D array is a line, a 1D array is written
+with one element per line. Views are written in their logical order.
+*/
+func SaveText[T Numeric](w io.Writer, arr *Array[T], opts SaveTextOptions) error {
+	if arr.Ndim > 2 {
+		return &ShapeError{Op: "SaveText", Shape: arr.Shape, Msg: "array must have 1 or 2 dimensions"}
+	}
+	delimiter := opts.Delimiter
+	if delimiter == 0 {
+		delimiter = ','
+	}
+	format := opts.Format
+	if format == "" {
+		format = "%v"
+	}
+	comments := opts.Comments
+	if comments == "" {
+		comments = "# "
+	}
+
+	bw := bufio.NewWriter(w)
+	writeComment := func(text string) {
+		if text == "" {
+			return
+		}
+		for _, line := range strings.Split(text, "\n") {
+			bw.WriteString(comments + line + "\n")
+		}
+	}
+
+	writeComment(opts.Header)
+	ncols := 1
+	if arr.Ndim == 2 {
+		ncols = arr.Shape[1]
+	}
+	col := 0
+	for it := arr.Iter(); it.Next(); {
+		if col > 0 {
+			bw.WriteRune(delimiter)
+		}
+		fmt.Fprintf(bw, format, arr.Data[it.Index()])
+		if col++; col == ncols {
+			bw.WriteByte('\n')
+			col = 0
+		}
+	}
+	writeComment(opts.Footer)
+	return bw.Flush()
+}
@@ -1,81 +1,144 @@
 package ndgo
 
+import (
+	"fmt"
+)
+
 // Unary operations
 // --------------------------------------------------------------
 
-// FromValues initializes the Array's data with values
-func (arr *Array) FromValues(values []float32) {
+// TryFromValues initializes the Array's data with values,
+// values are written in the logical (row major) order of the Array
+func (arr *Array[T]) TryFromValues(values []T) error {
 	if len(values) != arr.Totalsize {
-		panic("Values length must match Array's total size")
+		return &ShapeError{Op: "FromValues", Shape: arr.Shape, Msg: fmt.Sprintf("%d values given for array of size %d", len(values), arr.Totalsize)}
+	}
+	if arr.C_ORDER {
+		start := arr.Offset / arr.Itemsize
+		copy(arr.Data[start:start+arr.Totalsize], values)
+		return nil
+	}
+	i := 0
+	for it := arr.Iter(); it.Next(); i++ {
+		arr.Data[it.Index()] = values[i]
 	}
-	copy(arr.Data, values)
+	return nil
 }
 
-// Reshape an array to a new array according to new shape
-func (arr *Array) Reshape(shape []int) *Array {
-	var possible bool = checkShapeCompatible(arr, shape)
-	if !possible {
-		panic("ReshapeError: cannot reshape due to invalid given shape.")
+// FromValues is like TryFromValues but panics on error
+func (arr *Array[T]) FromValues(values []T) {
+	if err := arr.TryFromValues(values); err != nil {
+		panic(err)
 	}
+}
 
-	var res *Array = NewArrayFromShape(shape)
-	res.FromValues(arr.Data)
-
+// Copy returns a new C-contiguous Array with the same
+// shape and values, which does not share data with arr
+func (arr *Array[T]) Copy() *Array[T] {
+	res := NewArrayFromShape[T](arr.Shape)
+	i := 0
+	for it := arr.Iter(); it.Next(); i++ {
+		res.Data[i] = arr.Data[it.Index()]
+	}
 	return res
 }
 
+// checks that arr can be reshaped to shape
+func checkReshape[T Elem](arr *Array[T], shape []int, op string) error {
+	if len(shape) == 0 {
+		return &ShapeError{Op: op, Shape: shape, Msg: "shape must have at least one dimension"}
+	}
+	for _, v := range shape {
+		if v < 0 {
+			return &ShapeError{Op: op, Shape: shape, Msg: "negative dimensions are not allowed"}
+		}
+	}
+	if !checkShapeCompatible(arr, shape) {
+		return &ShapeError{Op: op, Shape: shape, Msg: fmt.Sprintf("cannot reshape array of size %d", arr.Totalsize)}
+	}
+	return nil
+}
+
+/*
+TryReshape reshapes an array according to new shape. If the array is
+C-contiguous the result is a view sharing data with arr, otherwise the
+data is copied first.
+*/
+func (arr *Array[T]) TryReshape(shape []int) (*Array[T], error) {
+	if err := checkReshape(arr, shape, "Reshape"); err != nil {
+		return nil, err
+	}
+
+	src := arr
+	if !arr.C_ORDER {
+		src = arr.Copy()
+	}
+
+	strides := make([]int, len(shape))
+	strides[len(shape)-1] = src.Itemsize
+	for i := len(shape) - 2; i >= 0; i-- {
+		strides[i] = strides[i+1] * shape[i+1]
+	}
+
+	return src.view(shape, strides, src.Offset), nil
+}
+
+// Reshape is like TryReshape but panics on error
+func (arr *Array[T]) Reshape(shape []int) *Array[T] {
+	return must(arr.TryReshape(shape))
+}
+
 /*
-transpose an Array along given permutation of axes,
+TryTranspose transposes an Array along given permutation of axes,
 here axes is a valid permutation of length equal to shape.
 If axes is nil, then axes will be reversed.
+
+The result is a view sharing data with arr.
 */
-func (arr *Array) Transpose(axes []int) *Array {
+func (arr *Array[T]) TryTranspose(axes []int) (*Array[T], error) {
 	// check if axes is valid
-	if axes != nil && !isValidPermutation(axes, arr.Ndim) {
-		panic("TransposeError: axes must be nil or a valid permutation.")
-	}
-
-	res := NewArrayFromShape(arr.Shape)
-	res.FromValues(arr.Data)
-	if arr.Ndim == 1 {
-		return res
+	if axes != nil {
+		if err := checkPermutation(axes, arr.Ndim, "Transpose"); err != nil {
+			return nil, err
+		}
 	}
 
-	_axes := make([]int, res.Ndim)
+	_axes := make([]int, arr.Ndim)
 	if axes == nil {
-		for i := 0; i < res.Ndim; i++ {
-			_axes[i] = res.Ndim - 1 - i
+		for i := 0; i < arr.Ndim; i++ {
+			_axes[i] = arr.Ndim - 1 - i
 		}
+	} else {
+		copy(_axes, axes)
 	}
 
-	newshape := make([]int, res.Ndim)
-	newstrides := make([]int, res.Ndim)
-	for i := 0; i < res.Ndim; i++ {
-		if axes != nil {
-			newshape[i] = arr.Shape[axes[i]]
-			newstrides[i] = arr.Strides[axes[i]]
-		} else {
-			newshape[i] = arr.Shape[_axes[i]]
-			newstrides[i] = arr.Strides[_axes[i]]
-		}
+	newshape := make([]int, arr.Ndim)
+	newstrides := make([]int, arr.Ndim)
+	for i := 0; i < arr.Ndim; i++ {
+		newshape[i] = arr.Shape[_axes[i]]
+		newstrides[i] = arr.Strides[_axes[i]]
 	}
-	copy(res.Shape, newshape)
-	copy(res.Strides, newstrides)
-	res.recalculateBackstrides()
-	res.createArrayIndices()
-	res.createLinearIndices()
-	res.setArrayFlags()
 
-	return res
+	return arr.view(newshape, newstrides, arr.Offset), nil
 }
 
-// Reshape an array inplace according to given shape
-func (arr *Array) Reshape_(shape []int) {
-	var possible bool = checkShapeCompatible(arr, shape)
-	ndim := len(shape)
+// Transpose is like TryTranspose but panics on error
+func (arr *Array[T]) Transpose(axes []int) *Array[T] {
+	return must(arr.TryTranspose(axes))
+}
 
-	if !possible || arr.Ndim < ndim || arr.Ndim > ndim {
-		panic("ReshapeError: cannot reshape due to invalid given shape.")
+// TryReshape_ reshapes an array inplace according to given shape,
+// the array must be C-contiguous and keep its number of dimensions
+func (arr *Array[T]) TryReshape_(shape []int) error {
+	if err := checkReshape(arr, shape, "Reshape_"); err != nil {
+		return err
+	}
+	ndim := len(shape)
+	if arr.Ndim != ndim {
+		return &ShapeError{Op: "Reshape_", Shape: shape, Msg: fmt.Sprintf("cannot change the number of dimensions %d inplace", arr.Ndim)}
+	}
+	if !arr.C_ORDER {
+		return &ShapeError{Op: "Reshape_", Shape: arr.Shape, Msg: "cannot reshape a non-contiguous array inplace"}
 	}
 
 	arr.Ndim = ndim
@@ -83,51 +146,58 @@ func (arr *Array) Reshape_(shape []int) {
 
 	arr.recalculateStrides()
 	arr.recalculateBackstrides()
-	arr.createArrayIndices()
 	arr.setArrayFlags()
+	return nil
+}
+
+// Reshape_ is like TryReshape_ but panics on error
+func (arr *Array[T]) Reshape_(shape []int) {
+	if err := arr.TryReshape_(shape); err != nil {
+		panic(err)
+	}
 }
 
 // Apply operations
 // ---------------------------------------------------------------
 
-func Neg(arr *Array) *Array {
-	res := Apply(arr, neg())
+func Neg[T Numeric](arr *Array[T]) *Array[T] {
+	res := Apply(arr, neg[T]())
 	return res
 }
 
 // e**x for all x in the Array
-func Exp(arr *Array) *Array {
-	res := Apply(arr, exp())
+func Exp[T Numeric](arr *Array[T]) *Array[T] {
+	res := Apply(arr, exp[T]())
 	return res
 }
 
 // ln(x) for all x in the Array
-func Log(arr *Array) *Array {
-	res := Apply(arr, log())
+func Log[T Numeric](arr *Array[T]) *Array[T] {
+	res := Apply(arr, log[T]())
 	return res
 }
 
-func Sin(arr *Array) *Array {
-	res := Apply(arr, sin())
+func Sin[T Numeric](arr *Array[T]) *Array[T] {
+	res := Apply(arr, sin[T]())
 	return res
 }
 
-func Cos(arr *Array) *Array {
-	res := Apply(arr, cos())
+func Cos[T Numeric](arr *Array[T]) *Array[T] {
+	res := Apply(arr, cos[T]())
 	return res
 }
 
-func Tan(arr *Array) *Array {
-	res := Apply(arr, tan())
+func Tan[T Numeric](arr *Array[T]) *Array[T] {
+	res := Apply(arr, tan[T]())
 	return res
 }
 
-func Tanh(arr *Array) *Array {
-	res := Apply(arr, tanh())
+func Tanh[T Numeric](arr *Array[T]) *Array[T] {
+	res := Apply(arr, tanh[T]())
 	return res
 }
 
-func Sigmoid(arr *Array) *Array {
-	res := Apply(arr, sigmoid())
+func Sigmoid[T Numeric](arr *Array[T]) *Array[T] {
+	res := Apply(arr, sigmoid[T]())
 	return res
 }
@@ -1,6 +1,11 @@
 package ndgo
 
-import "errors"
+import (
+	"errors"
+	"runtime"
+	"sort"
+	"sync"
+)
 
 /*
 Check shapes equal
@@ -25,6 +30,15 @@ func CheckShapesEqual(left, right []int) bool {
 	return equal
 }
 
+// ShapeSize is the number of elements of an array with the given shape
+func ShapeSize(shape []int) int {
+	size := 1
+	for _, v := range shape {
+		size *= v
+	}
+	return size
+}
+
 /*
 Broadcast the two shapes to give a final shape
 */
@@ -75,10 +89,11 @@ func broadcastShapes(left, right []int) ([]int, error) {
 
 	for i := 0; i < res_ndim; i++ {
 		if lf_shape[i] == 1 || rf_shape[i] == 1 || lf_shape[i] == rf_shape[i] {
-			if lf_shape[i] > rf_shape[i] {
-				res_shape[i] = lf_shape[i]
-			} else {
+			// a dimension of 1 takes the other size, even 0
+			if lf_shape[i] == 1 {
 				res_shape[i] = rf_shape[i]
+			} else {
+				res_shape[i] = lf_shape[i]
 			}
 		} else {
 			return nil, errors.New("shapes are not broadcastable")
@@ -94,43 +109,147 @@ broadcasts an array given a new shape and its dimensions.
 note: errors are not checked, and it is to be assumed that the Array
 is "broadcastable" to the new shape.
 
-if you use this function, you will have to manually free the result of broadcasted array
+the result is a read-only view of arr, broadcasted and prepended axes
+get a stride of 0 so no data is copied.
 */
-func broadcastArray(arr *Array, shape []int) *Array {
-	res := NewArrayFromShape(shape)
-
+func broadcastArray[T Elem](arr *Array[T], shape []int) *Array[T] {
 	n_prepend := len(shape) - arr.Ndim
+	strides := make([]int, len(shape))
 
-	for i := 0; i < res.Totalsize; i++ {
-		srcIdx := 0
-		for dim := 0; dim < arr.Ndim; dim++ {
-			// for dimensions which are not singleton,
-			// use result's n-dimensional index to
-			// calculate the 1D index in source array
-			if arr.Shape[dim] > 1 {
-				srcIdx += (res.Idxs.Indices[i][n_prepend+dim] % arr.Shape[dim]) * arr.Strides[dim]
-			}
+	for dim := 0; dim < arr.Ndim; dim++ {
+		// singleton dimensions are repeated along the new shape
+		if arr.Shape[dim] != 1 || shape[n_prepend+dim] == 1 {
+			strides[n_prepend+dim] = arr.Strides[dim]
 		}
+	}
+
+	return arr.view(shape, strides, arr.Offset)
+}
 
-		res.Data[res.Lidxs.Indices[i]] = arr.Data[srcIdx/arr.Itemsize]
+// TryBroadcastShapes returns the shape that a and b broadcast to
+func TryBroadcastShapes(a, b []int) ([]int, error) {
+	shape, err := broadcastShapes(a, b)
+	if err != nil {
+		return nil, &BroadcastError{Op: "BroadcastShapes", Shapes: [][]int{a, b}}
 	}
+	return shape, nil
+}
+
+// BroadcastShapes is like TryBroadcastShapes but panics on error
+func BroadcastShapes(a, b []int) []int {
+	return must(TryBroadcastShapes(a, b))
+}
 
-	return res
+/*
+TryBroadcastTo returns arr broadcast to shape as a read-only view, the
+repeated axes have a stride of 0 so no data is copied.
+*/
+func TryBroadcastTo[T Elem](arr *Array[T], shape []int) (*Array[T], error) {
+	res_shape, err := broadcastShapes(arr.Shape, shape)
+	if err != nil || !CheckShapesEqual(res_shape, shape) {
+		return nil, &BroadcastError{Op: "BroadcastTo", Shapes: [][]int{arr.Shape, shape}}
+	}
+	return broadcastArray(arr, shape), nil
 }
 
-func isValidPermutation(slice []int, n int) bool {
+// BroadcastTo is like TryBroadcastTo but panics on error
+func BroadcastTo[T Elem](arr *Array[T], shape []int) *Array[T] {
+	return must(TryBroadcastTo(arr, shape))
+}
+
+// checkPermutation returns an AxisError unless axes is a permutation of [0, n)
+func checkPermutation(axes []int, n int, op string) error {
 	seen := make([]bool, n)
+	for _, ax := range axes {
+		if ax < 0 || ax >= n {
+			return &AxisError{Op: op, Axis: ax, Ndim: n, Msg: "is out of bounds"}
+		}
+		if seen[ax] {
+			return &AxisError{Op: op, Axis: ax, Ndim: n, Msg: "is repeated"}
+		}
+		seen[ax] = true
+	}
+	for ax, ok := range seen {
+		if !ok {
+			return &AxisError{Op: op, Axis: ax, Ndim: n, Msg: "is missing from the permutation"}
+		}
+	}
+	return nil
+}
+
+/*
+parallelFor splits the range [0, n) into one chunk per available
+processor and calls fn on every chunk concurrently, waiting for all
+of them to finish.
+*/
+func parallelFor(n int, fn func(start, end int)) {
+	n_routines := runtime.GOMAXPROCS(0)
+	if n_routines > n {
+		n_routines = n
+	}
+	if n_routines <= 1 {
+		fn(0, n)
+		return
+	}
+	var chunk_size int = (n + n_routines - 1) / n_routines
 
-	for _, num := range slice {
-		if num < 0 || num >= n {
-			return false
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += chunk_size {
+		end := start + chunk_size
+		if end > n {
+			end = n
 		}
-		// when the number repeats
-		if seen[num] {
-			return false
+
+		wg.Add(1)
+		go func(s, e int) {
+			defer wg.Done()
+			fn(s, e)
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+/*
+CheckAxes checks the given axes against ndim and returns them in the
+same order with negative axes counted from the end, or an AxisError for
+an axis out of bounds or repeated. op names the operation in the error.
+*/
+func CheckAxes(axes []int, ndim int, op string) ([]int, error) {
+	seen := make([]bool, ndim)
+	res := make([]int, len(axes))
+	for i, ax := range axes {
+		if ax < -ndim || ax >= ndim {
+			return nil, &AxisError{Op: op, Axis: ax, Ndim: ndim, Msg: "is out of bounds"}
+		}
+		if ax < 0 {
+			ax += ndim
 		}
-		seen[num] = true
+		if seen[ax] {
+			return nil, &AxisError{Op: op, Axis: ax, Ndim: ndim, Msg: "is repeated"}
+		}
+		seen[ax] = true
+		res[i] = ax
 	}
+	return res, nil
+}
 
-	return true
+/*
+normalizeAxes checks the given axes against ndim and returns them
+sorted with negative axes counted from the end. nil selects all axes.
+*/
+func normalizeAxes(axes []int, ndim int, op string) ([]int, error) {
+	if axes == nil {
+		res := make([]int, ndim)
+		for i := range res {
+			res[i] = i
+		}
+		return res, nil
+	}
+
+	res, err := CheckAxes(axes, ndim, op)
+	if err != nil {
+		return nil, err
+	}
+	sort.Ints(res)
+	return res, nil
 }
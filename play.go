@@ -6,8 +6,8 @@ import (
 )
 
 func main() {
-	a := ng.Arange(1, 4, 1).Reshape([]int{3, 1})
-	b := ng.Arange(1, 3, 1).Reshape([]int{2})
+	a := ng.Arange[float32](1, 4, 1).Reshape([]int{3, 1})
+	b := ng.Arange[float32](1, 3, 1).Reshape([]int{2})
 
 	c := ng.Add(a, b)
 